@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/kmpm/unionfees/internal/anonymize"
+	"github.com/kmpm/unionfees/internal/output"
+)
+
+func newAnonymizeCmd() *command {
+	var out string
+	var seed uint64
+	var force bool
+	c := newCommand("anonymize", "<filename.pdf|json>", "Ersätt namn, personnummer, företag och belopp för att kunna dela en rapport i en felrapport")
+	c.debugPDF = func() bool { return out != "" }
+	c.flags.StringVar(&out, "out", "", "fil att skriva till (default stdout)")
+	c.flags.Uint64Var(&seed, "seed", 0, "samma seed ger samma ersättningar (default slumpat)")
+	c.flags.BoolVar(&force, "f", false, "skriv över befintlig fil")
+	c.run = func(args []string) error {
+		if len(args) != 1 {
+			c.usage()
+			return withCode(exitUsage, fmt.Errorf("filnamn för pdf eller json måste anges"))
+		}
+		rep, err := loadReport(args[0], "", "", "", nil)
+		if err != nil {
+			return withCode(exitInput, err)
+		}
+		src, err := rep.document("anonymize")
+		if err != nil {
+			return err
+		}
+		if !c.isFlagPassed("seed") {
+			seed = uint64(time.Now().UnixNano())
+		}
+		a := anonymize.New(seed)
+		doc := a.Document(src, rep.CompanyName, rep.OrgNum)
+
+		if out == "" {
+			err = doc.Save(os.Stdout)
+		} else {
+			err = output.WriteFile(out, force, func(w io.Writer) error {
+				return doc.Save(w)
+			})
+		}
+		if errors.Is(err, output.ErrExists) {
+			return withCode(exitExists, fmt.Errorf("filen '%s' finns redan, ange -f för att skriva över", out))
+		}
+		if err != nil {
+			return withCode(exitWrite, err)
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", a.Stats)
+		fmt.Fprintln(os.Stderr, "Kontrollera resultatet med inspect innan det delas, text som inte känns igen ersätts inte.")
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,255 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/kmpm/unionfees/internal"
+)
+
+type batchFlags struct {
+	date    string
+	jobs    int
+	outDir  string
+	tmpl    string
+	force   bool
+	test    bool
+	correct bool
+	maxAge  int
+	split   bool
+	unions  string
+	dryRun  bool
+	json    bool
+	config  string
+}
+
+func newBatchCmd() *command {
+	var f batchFlags
+	c := newCommand("batch", "<katalog>...", "Gör om alla pdf-filer i en eller flera kataloger, flera åt gången")
+	c.flags.StringVar(&f.date, "d", "", "utbetalningsdatum ÅÅMMDD (default från filnamnet)")
+	c.flags.IntVar(&f.jobs, "j", runtime.NumCPU(), "hur många filer som görs om samtidigt")
+	c.flags.StringVar(&f.outDir, "out", "", "katalog där filerna skapas (default från konfiguration eller aktuell katalog)")
+	c.flags.StringVar(&f.tmpl, "filnamn", "", "mall för filnamn, se convert -h")
+	c.flags.BoolVar(&f.force, "f", false, "skriv över befintliga filer")
+	c.flags.BoolVar(&f.test, "test", false, "filerna är för en testinskickning")
+	c.flags.BoolVar(&f.correct, "rattelse", false, "skapa rättelser, krävs för äldre, stängda perioder")
+	c.flags.IntVar(&f.maxAge, "maxage", internal.DefaultPeriodPolicy.MaxAge, "hur många månader bakåt en rättelse får avse")
+	c.flags.BoolVar(&f.split, "perplats", false, "skapa en fil per plats (arbetsställe)")
+	c.flags.StringVar(&f.unions, "forbund", "", "kommaseparerade förbund att skapa filer för (default alla)")
+	c.flags.BoolVar(&f.dryRun, "dry-run", false, "tolka och kontrollera filerna utan att skapa några")
+	c.flags.BoolVar(&f.json, "json", false, "skriv resultatet för varje fil som json på stdout")
+	c.flags.StringVar(&f.config, "config", "", "konfigurationsfil")
+	c.run = func(args []string) error {
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+		if f.jobs < 1 {
+			return withCode(exitUsage, fmt.Errorf("-j måste vara minst 1"))
+		}
+		files, err := findPDFs(args)
+		if err != nil {
+			return withCode(exitInput, err)
+		}
+		if len(files) == 0 {
+			return withCode(exitInput, fmt.Errorf("inga pdf-filer i %s", strings.Join(args, ", ")))
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		results := runBatch(ctx, &f, files, os.Stderr)
+		return reportBatch(&f, results, os.Stdout)
+	}
+	return c
+}
+
+// findPDFs returns the pdf files in the directory trees, in lexical order
+func findPDFs(dirs []string) ([]string, error) {
+	var files []string
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".pdf") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// batchResult is the result of one file, res is never nil
+type batchResult struct {
+	res *convertResult
+	err error
+	// skipped is set for files not started before the batch was interrupted
+	skipped bool
+}
+
+// runBatch converts files with at most f.jobs at a time, a failed file
+// does not stop the others. Progress is written to out.
+func runBatch(ctx context.Context, f *batchFlags, files []string, out io.Writer) []batchResult {
+	results := make([]batchResult, len(files))
+	for i, file := range files {
+		res := &convertResult{File: file, Unions: []unionResult{}, Warnings: []string{}}
+		res.Error, res.ExitCode = "avbruten", exitFailure
+		results[i] = batchResult{res: res, skipped: true}
+	}
+
+	var mu sync.Mutex // guards done and out
+	done := 0
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(f.jobs, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				res, err := convertOne(f, files[i])
+				results[i] = batchResult{res: res, err: err}
+
+				mu.Lock()
+				done++
+				status := "OK"
+				if err != nil {
+					status = "FEL"
+				}
+				fmt.Fprintf(out, "[%d/%d] %s: %s\n", done, len(files), files[i], status)
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for i := range files {
+		// select picks at random when both are ready
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+// convertOne converts file like convert would with the batch flags
+func convertOne(f *batchFlags, file string) (*convertResult, error) {
+	date := f.date
+	if date == "" {
+		d, ok := dateFromName(filepath.Base(file))
+		if !ok {
+			res := &convertResult{File: file, Unions: []unionResult{}, Warnings: []string{}}
+			err := withCode(exitUsage, fmt.Errorf("utbetalningsdatum saknas i filnamnet och -d är inte angivet"))
+			res.finish(err)
+			return res, err
+		}
+		date = d
+	}
+	cf := convertFlags{
+		date:    date,
+		correct: f.correct,
+		maxAge:  f.maxAge,
+		outDir:  f.outDir,
+		tmpl:    f.tmpl,
+		force:   f.force,
+		test:    f.test,
+		split:   f.split,
+		unions:  f.unions,
+		dryRun:  f.dryRun,
+		config:  f.config,
+	}
+	res, err := runConvert(&cf, []string{file}, io.Discard)
+	res.finish(err)
+	return res, err
+}
+
+// reportBatch writes the summary table, or json, and returns an error
+// if any file failed
+func reportBatch(f *batchFlags, results []batchResult, out io.Writer) error {
+	failed, skipped := 0, 0
+	for _, r := range results {
+		switch {
+		case r.skipped:
+			skipped++
+		case r.err != nil:
+			failed++
+		}
+	}
+
+	if f.json {
+		list := make([]*convertResult, len(results))
+		for i, r := range results {
+			list[i] = r.res
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(list); err != nil {
+			return err
+		}
+	} else {
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "Fil\tFöretag\tPeriod\tFörbund\tStatus")
+		for _, r := range results {
+			period := ""
+			if r.res.Period > 0 {
+				period = fmt.Sprintf("%02d/%02d", r.res.Period, r.res.Year)
+			}
+			names := []string{}
+			for _, u := range r.res.Unions {
+				names = append(names, u.Name)
+			}
+			status := "OK"
+			switch {
+			case r.skipped:
+				status = "avbruten"
+			case r.err != nil:
+				status = "FEL"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.res.File, r.res.Company, period, strings.Join(names, ", "), status)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if failed > 0 {
+			fmt.Fprintln(out)
+		}
+		for _, r := range results {
+			if r.err != nil {
+				fmt.Fprintf(out, "Fel i %s: %v\n", r.res.File, r.err)
+			}
+		}
+	}
+
+	if failed == 0 && skipped == 0 {
+		return nil
+	}
+	err := fmt.Errorf("%d av %d filer kunde inte göras om", failed, len(results))
+	if skipped > 0 {
+		err = fmt.Errorf("%w, %d avbröts innan de påbörjades", err, skipped)
+	}
+	if f.json {
+		err = fmt.Errorf("%w: %w", errReported, err)
+	}
+	return withCode(exitFailure, err)
+}
@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kmpm/unionfees/internal/output"
+	"github.com/kmpm/unionfees/internal/pdftest"
+)
+
+// batchDir writes two copies of the same report, that give the same
+// file names, and a broken pdf to a directory
+func batchDir(t *testing.T) (*batchFlags, []string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("UNIONFEES_HISTORY", filepath.Join(dir, "historik.jsonl"))
+	cfg := filepath.Join(dir, "unionfees.toml")
+	if err := os.WriteFile(cfg, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	date := now.Format("060102")
+	r := pdftest.Example()
+	r.Period = now.Format("2006-01")
+	in := filepath.Join(dir, "in")
+	if err := os.Mkdir(in, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := []string{
+		filepath.Join(in, "a-"+date+".pdf"),
+		filepath.Join(in, "b-"+date+".pdf"),
+		filepath.Join(in, "c-"+date+".pdf"),
+	}
+	for _, f := range files[:2] {
+		if err := pdftest.WriteFile(f, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(files[2], []byte("inte en pdf"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &batchFlags{jobs: 3, outDir: filepath.Join(dir, "ut"), config: cfg}, files
+}
+
+func TestRunBatch(t *testing.T) {
+	f, files := batchDir(t)
+	var progress strings.Builder
+	results := runBatch(context.Background(), f, files, &progress)
+
+	exists, failed := 0, 0
+	for _, r := range results {
+		if r.skipped {
+			t.Errorf("%s was skipped", r.res.File)
+		}
+		if r.err != nil {
+			failed++
+		}
+		if errors.Is(r.err, output.ErrExists) || exitCode(r.err) == exitExists {
+			exists++
+		}
+	}
+	// the copies run at the same time, only one may write the file
+	if failed != 2 || exists != 1 {
+		t.Errorf("%d failed and %d existed, want 2 and 1", failed, exists)
+	}
+	if results[2].err == nil || results[2].res.ExitCode != exitInput {
+		t.Errorf("broken pdf = %v, exit code %d", results[2].err, results[2].res.ExitCode)
+	}
+	if n := strings.Count(progress.String(), "\n"); n != len(files) {
+		t.Errorf("progress has %d lines, want %d:\n%s", n, len(files), progress.String())
+	}
+	entries, err := os.ReadDir(f.outDir)
+	if err != nil || len(entries) != 2 {
+		t.Errorf("output = %v, %v, want a file per union", entries, err)
+	}
+
+	var out strings.Builder
+	err = reportBatch(f, results, &out)
+	if exitCode(err) != exitFailure || !strings.Contains(err.Error(), "2 av 3 filer") {
+		t.Errorf("reportBatch() error = %v", err)
+	}
+	for _, want := range []string{"Fil", "Exempel AB", "IF Metall", "OK", "FEL", "Fel i " + files[2]} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("summary has no %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunBatchInterrupted(t *testing.T) {
+	f, files := batchDir(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := runBatch(ctx, f, files, &strings.Builder{})
+
+	var out strings.Builder
+	err := reportBatch(f, results, &out)
+	started := 0
+	for _, r := range results {
+		if !r.skipped {
+			started++
+		}
+	}
+	if started != 0 || !strings.Contains(err.Error(), "avbröts innan de påbörjades") {
+		t.Errorf("%d started, reportBatch() error = %v", started, err)
+	}
+	if !strings.Contains(out.String(), "avbruten") {
+		t.Errorf("summary has no interrupted file:\n%s", out.String())
+	}
+}
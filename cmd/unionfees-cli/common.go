@@ -0,0 +1,181 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kmpm/unionfees/internal"
+	"github.com/kmpm/unionfees/internal/history"
+	"github.com/kmpm/unionfees/internal/input"
+	"github.com/kmpm/unionfees/internal/parser"
+	"github.com/kmpm/unionfees/internal/union"
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/kmpm/unionfees/public/unionfees"
+)
+
+// report is a parsed pdf, or other payroll export, together with the
+// company it belongs to
+type report struct {
+	Filename    string
+	Format      string
+	Doc         *parser.Document // nil unless read from a pdf
+	Tables      map[string][]parser.TableRow
+	CompanyName string
+	OrgNum      string
+	CompanyNum  int
+
+	in *input.Input
+}
+
+// cells returns the cells of the rows per union
+func (r *report) cells() map[string][][]string {
+	return (&input.Input{Tables: r.Tables}).Cells()
+}
+
+// document returns the parsed pdf, or an error naming what needs it
+func (r *report) document(what string) (*parser.Document, error) {
+	if r.Doc == nil {
+		return nil, withCode(exitUsage, fmt.Errorf("%s kräver en pdf, %s är %s", what, r.Filename, r.Format))
+	}
+	return r.Doc, nil
+}
+
+// stdinName as file name reads the pdf from stdin
+const stdinName = "-"
+
+// loadReport reads a pdf or an export from another payroll system.
+// Empty name and num are taken from the input. The format is detected
+// unless given. Nil adapters means the built in ones.
+func loadReport(filename, name, num, format string, adapters []input.Adapter) (*report, error) {
+	if filename == "" {
+		return nil, fmt.Errorf("filnamn för pdf måste anges")
+	}
+	if adapters == nil {
+		adapters = input.Adapters(nil, nil)
+	}
+	var data []byte
+	var err error
+	if filename == stdinName {
+		data, err = readStdin()
+	} else {
+		data, err = os.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fel vid läsning av pdf: %w", err)
+	}
+	in, err := input.Read(context.Background(), filename, data, format, adapters)
+	if err != nil {
+		return nil, fmt.Errorf("fel vid läsning av %s: %w", filename, err)
+	}
+
+	name = cmp.Or(name, in.CompanyName)
+	if len(name) < 3 {
+		return nil, fmt.Errorf("namn måste vara längre än 3 tecken")
+	}
+
+	num = cmp.Or(num, in.OrgNum)
+	cn, err := internal.Str2Person(num)
+	if err != nil {
+		return nil, fmt.Errorf("felaktigt orgnr: %w", err)
+	}
+
+	return &report{
+		Filename:    filename,
+		Format:      in.Format,
+		Doc:         in.Doc,
+		Tables:      in.Tables,
+		CompanyName: name,
+		OrgNum:      num,
+		CompanyNum:  cn,
+		in:          in,
+	}, nil
+}
+
+// openHistory returns the history of converted files, nil if there is none
+func openHistory(cfg *unionfees.Config) *history.Store {
+	path := cfg.HistoryPath()
+	if path == "" {
+		return nil
+	}
+	return history.Open(path)
+}
+
+// historyEntry records r, with the unions that got files
+func historyEntry(r *unionfees.Result, test bool) history.Entry {
+	e := history.Entry{
+		Hash:       r.Hash,
+		File:       r.File,
+		Company:    r.CompanyName,
+		OrgNum:     r.OrgNum,
+		Year:       r.Year,
+		Period:     r.Period,
+		Accounting: r.Accounting,
+		Test:       test,
+		Unions:     []int{},
+		Files:      []string{},
+	}
+	for _, u := range r.Unions {
+		if len(u.Files) > 0 {
+			e.Unions = append(e.Unions, u.Code)
+		}
+	}
+	for _, file := range r.Files() {
+		e.Files = append(e.Files, file.Name)
+	}
+	return e
+}
+
+// readInput reads filename, or stdin for stdinName, for unionfees.Convert
+func readInput(filename, format string) (unionfees.Input, error) {
+	if filename == stdinName {
+		data, err := readStdin()
+		return unionfees.Input{Name: filename, Data: data, Format: format}, err
+	}
+	in, err := unionfees.ReadFile(filename)
+	in.Format = format
+	return in, err
+}
+
+// readStdin reads all of stdin, a pdf has to be read in full since
+// the pdf reader needs random access
+func readStdin() ([]byte, error) {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("ingen pdf på stdin")
+	}
+	return data, nil
+}
+
+// readUnionFile reads a union file as written by convert, in the named
+// encoding, empty for the default.
+func readUnionFile(filename, encoding string) (spec.Locations, union.UnionCode, error) {
+	enc, err := union.Encoding(encoding)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	return union.ReadTableEncoding(f, enc)
+}
+
+// readOriginal reads an earlier submitted union file.
+func readOriginal(filename, encoding string) ([]spec.S2Spec, union.UnionCode, error) {
+	locs, code, err := readUnionFile(filename, encoding)
+	if err != nil {
+		return nil, code, err
+	}
+	return internal.AllS2(locs), code, nil
+}
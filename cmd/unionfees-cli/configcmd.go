@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/kmpm/unionfees/internal/config"
+)
+
+func newConfigCmd() *command {
+	var path string
+	c := newCommand("config", "check|example", "Kontrollera konfigurationen eller visa en exempelfil")
+	c.flags.StringVar(&path, "config", "", "konfigurationsfil")
+	c.run = func(args []string) error {
+		if len(args) != 1 {
+			c.usage()
+			return withCode(exitUsage, fmt.Errorf("ange check eller example"))
+		}
+		switch args[0] {
+		case "example":
+			fmt.Print(config.Example)
+			return nil
+		case "check":
+			return configCheck(path)
+		}
+		c.usage()
+		return withCode(exitUsage, fmt.Errorf("okänt kommando: %s", args[0]))
+	}
+	return c
+}
+
+func configCheck(path string) error {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return withCode(exitConfig, err)
+	}
+	if cfg.Path == "" {
+		fmt.Println("Ingen konfigurationsfil hittades, använder standardvärden")
+	} else {
+		fmt.Printf("Fil:      \t%s\n", cfg.Path)
+	}
+	fmt.Printf("Företag:  \t%s\n", cfg.Company.Name)
+	fmt.Printf("Orgnr:    \t%s\n", cfg.Company.OrgNum)
+	for _, k := range slices.Sorted(maps.Keys(cfg.Unions)) {
+		fmt.Printf("Förbund:  \t%s = %d\n", k, cfg.Unions[k])
+	}
+	fmt.Printf("Plats:    \t%d, %d personer med egen plats\n", cfg.DefaultLocation, len(cfg.Locations))
+	fmt.Printf("Betalkoder:\t%d regler\n", len(cfg.PayCodes))
+	fmt.Printf("Katalog:  \t%s\n", cfg.Output.Dir)
+	fmt.Printf("Filnamn:  \t%s\n", cfg.Output.Template)
+	fmt.Printf("Kodning:  \t%s\n", cfg.Output.Encoding)
+	if err := cfg.Check(); err != nil {
+		fmt.Printf("\n%v\n", err)
+		return withCode(exitConfig, fmt.Errorf("konfigurationen är felaktig"))
+	}
+	fmt.Println("\nKonfigurationen är OK")
+	return nil
+}
@@ -0,0 +1,466 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kmpm/unionfees/internal"
+	"github.com/kmpm/unionfees/internal/config"
+	"github.com/kmpm/unionfees/internal/history"
+	"github.com/kmpm/unionfees/internal/output"
+	"github.com/kmpm/unionfees/internal/union"
+	"github.com/kmpm/unionfees/internal/xlsx"
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/kmpm/unionfees/public/unionfees"
+	"github.com/shopspring/decimal"
+)
+
+type convertFlags struct {
+	num     string
+	name    string
+	period  int
+	year    int
+	date    string
+	print   bool
+	correct bool
+	supp    bool
+	orig    string
+	only    string
+	maxAge  int
+	outDir  string
+	tmpl    string
+	force   bool
+	test    bool
+	split   bool
+	json    bool
+	unions  string
+	config  string
+	dryRun  bool
+	explain bool
+	stdout  bool
+	zip     bool
+	format  string
+	xlsx    string
+
+	periodSet bool      // -m given
+	yearSet   bool      // -y given
+	stream    io.Writer // where -stdout and -zip write
+}
+
+func newConvertCmd() *command {
+	return newConvertLikeCmd("convert", "Gör om pdf till fil för fackförbundet", false)
+}
+
+func newExplainCmd() *command {
+	return newConvertLikeCmd("explain", "Visa varje rad i filen med var i pdf:en den kommer ifrån", true)
+}
+
+// newConvertLikeCmd creates convert or explain, explain shows the
+// provenance of each record instead of writing files
+func newConvertLikeCmd(name, short string, explain bool) *command {
+	f := convertFlags{explain: explain}
+	c := newCommand(name, "<filename.pdf|->", short)
+	c.flags.StringVar(&f.num, "o", "", "organisationsnummer (default från konfiguration eller pdf)")
+	c.flags.StringVar(&f.name, "n", "", "företagsnamn (default från konfiguration eller pdf)")
+	c.flags.IntVar(&f.period, "m", 0, "redovisningsperiod MM (default från datum)")
+	c.flags.IntVar(&f.year, "y", 0, "redovisningår ÅÅ (default från datum)")
+	c.flags.StringVar(&f.date, "d", "", "utbetalningsdatum ÅÅMMDD")
+	c.flags.BoolVar(&f.print, "print", false, "Visa det tolkade dokumentet")
+	c.flags.StringVar(&f.format, "format", "", "indataformat, pdf, xlsx, visma-csv eller csv (default känns igen)")
+	c.flags.BoolVar(&f.correct, "rattelse", false, "skapa en rättelse, krävs för äldre, stängda perioder")
+	c.flags.BoolVar(&f.supp, "tillagg", false, "skapa ett tillägg till en tidigare redovisning")
+	c.flags.StringVar(&f.orig, "original", "", "tidigare inskickad fil att räkna rättelse/tillägg mot")
+	c.flags.StringVar(&f.only, "endast", "", "kommaseparerade personnummer som ska med i rättelsen/tillägget")
+	c.flags.IntVar(&f.maxAge, "maxage", internal.DefaultPeriodPolicy.MaxAge, "hur många månader bakåt en rättelse får avse")
+	c.flags.StringVar(&f.outDir, "out", "", "katalog där filerna skapas (default från konfiguration eller aktuell katalog)")
+	c.flags.StringVar(&f.tmpl, "filnamn", "", "mall för filnamn, fält: .Union .Code .Company .OrgNum .Year .Period .Location .Test .Env")
+	c.flags.BoolVar(&f.force, "f", false, "skriv över befintliga filer")
+	c.flags.BoolVar(&f.test, "test", false, "filen är för en testinskickning")
+	c.flags.BoolVar(&f.split, "perplats", false, "skapa en fil per plats (arbetsställe)")
+	c.flags.BoolVar(&f.json, "json", false, "skriv resultatet som json på stdout")
+	c.flags.StringVar(&f.unions, "forbund", "", "kommaseparerade förbund att skapa filer för (default alla)")
+	if !explain {
+		c.flags.BoolVar(&f.dryRun, "dry-run", false, "visa posterna med kolumnlinjal i stället för att skapa filer")
+		c.flags.BoolVar(&f.stdout, "stdout", false, "skriv filen till stdout i stället för att skapa den, kräver att det blir en enda fil")
+		c.flags.BoolVar(&f.zip, "zip", false, "skriv alla filer i ett zip-arkiv till stdout")
+		c.flags.StringVar(&f.xlsx, "xlsx", "", "skriv medlemslistorna till en xlsx-fil för granskning i stället för att skapa filerna")
+	}
+	c.flags.StringVar(&f.config, "config", "", "konfigurationsfil (default "+config.FileName+" eller "+config.EnvPrefix+"CONFIG)")
+	c.run = func(args []string) error {
+		f.periodSet = c.isFlagPassed("m")
+		f.yearSet = c.isFlagPassed("y")
+		f.stream = os.Stdout
+		progress := io.Writer(os.Stdout)
+		if f.stdout || f.zip {
+			// stdout is for the file, keep messages for humans apart
+			progress = os.Stderr
+		}
+		if !f.json {
+			_, err := runConvert(&f, args, progress)
+			if exitCode(err) == exitUsage {
+				c.usage()
+			}
+			return err
+		}
+		res, err := runConvert(&f, args, io.Discard)
+		res.finish(err)
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if jerr := enc.Encode(res); jerr != nil {
+			return jerr
+		}
+		if err != nil {
+			return withCode(exitCode(err), fmt.Errorf("%w: %w", errReported, err))
+		}
+		return nil
+	}
+	return c
+}
+
+// convertResult is the machine readable result of convert -json
+type convertResult struct {
+	File           string        `json:"file"`
+	Hash           string        `json:"sha256"`
+	Company        string        `json:"company"`
+	OrgNum         string        `json:"orgnum"`
+	PayoutDate     string        `json:"payoutDate"`
+	Year           int           `json:"year"`
+	Period         int           `json:"period"`
+	AccountingType int           `json:"accountingType"`
+	DryRun         bool          `json:"dryRun"`
+	Unions         []unionResult `json:"unions"`
+	Warnings       []string      `json:"warnings"`
+	Error          string        `json:"error,omitempty"`
+	ExitCode       int           `json:"exitCode"`
+}
+
+type unionResult struct {
+	Name      string           `json:"name"`
+	Code      int              `json:"code"`
+	Records   int              `json:"records"`
+	Sum       decimal.Decimal  `json:"sum"`
+	Locations []locationResult `json:"locations"`
+	Files     []string         `json:"files"`
+}
+
+type locationResult struct {
+	LocNum  int             `json:"location"`
+	Records int             `json:"records"`
+	Sum     decimal.Decimal `json:"sum"`
+}
+
+func (r *convertResult) warn(out io.Writer, msg string) {
+	r.Warnings = append(r.Warnings, msg)
+	fmt.Fprintf(out, "Varning: %s\n", msg)
+}
+
+func (r *convertResult) finish(err error) {
+	r.ExitCode = exitCode(err)
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// options are the unionfees.Options for the flags, cfg is the loaded config
+func (f *convertFlags) options(cfg *unionfees.Config) (unionfees.Options, error) {
+	var opts unionfees.Options
+	t, err := time.Parse("060102", f.date)
+	if err != nil {
+		return opts, withCode(exitUsage, fmt.Errorf("felaktigt datum: %w", err))
+	}
+	opts = unionfees.Options{
+		Config:       cfg,
+		CompanyName:  f.name,
+		OrgNum:       f.num,
+		PayoutDate:   t,
+		MaxAge:       f.maxAge,
+		FileTemplate: f.tmpl,
+		Split:        f.split,
+		Test:         f.test,
+	}
+	if f.periodSet {
+		opts.Period = f.period
+	}
+	if f.yearSet {
+		opts.Year = f.year
+	}
+	if f.correct && f.supp {
+		return opts, withCode(exitUsage, fmt.Errorf("-rattelse och -tillagg kan inte användas samtidigt"))
+	}
+	if f.correct {
+		opts.Accounting = spec.AccountingCorrection
+	}
+	if f.supp {
+		opts.Accounting = spec.AccountingSupplement
+	}
+	if f.orig != "" {
+		if opts.Accounting == spec.AccountingNormal {
+			return opts, withCode(exitUsage, fmt.Errorf("-original kräver -rattelse eller -tillagg"))
+		}
+		var code union.UnionCode
+		opts.Original, code, err = readOriginal(f.orig, cfg.Output.Encoding)
+		if err != nil {
+			return opts, withCode(exitInput, fmt.Errorf("fel vid läsning av '%s': %w", f.orig, err))
+		}
+		opts.OriginalCode = int(code)
+	}
+	if f.only != "" {
+		opts.Only, err = internal.ParsePersons(f.only)
+		if err != nil {
+			return opts, withCode(exitUsage, err)
+		}
+	}
+	if f.unions != "" {
+		opts.Unions = strings.Split(f.unions, ",")
+	}
+	return opts, nil
+}
+
+// runConvert does the conversion, writing progress for humans to out.
+// The returned result is never nil.
+func runConvert(f *convertFlags, args []string, out io.Writer) (*convertResult, error) {
+	res := &convertResult{Unions: []unionResult{}, Warnings: []string{}, DryRun: f.dryRun || f.explain}
+	cfg, err := unionfees.LoadConfig(f.config)
+	if err != nil {
+		return res, withCode(exitConfig, err)
+	}
+	if f.stdout || f.zip {
+		switch {
+		case f.stdout && f.zip:
+			return res, withCode(exitUsage, fmt.Errorf("-stdout och -zip kan inte användas samtidigt"))
+		case f.json:
+			return res, withCode(exitUsage, fmt.Errorf("-json kan inte användas med -stdout eller -zip"))
+		case f.dryRun || f.explain:
+			return res, withCode(exitUsage, fmt.Errorf("-stdout och -zip skapar filer, de kan inte förhandsvisas"))
+		case f.stream == nil:
+			return res, withCode(exitUsage, fmt.Errorf("-stdout och -zip stöds inte här"))
+		}
+	}
+	if f.xlsx != "" && (f.stdout || f.zip || f.dryRun || f.explain) {
+		return res, withCode(exitUsage, fmt.Errorf("-xlsx kan inte användas med -stdout, -zip eller -dry-run"))
+	}
+	outDir := cmp.Or(f.outDir, cfg.Output.Dir, ".")
+
+	opts, err := f.options(cfg)
+	if err != nil {
+		return res, err
+	}
+
+	if len(args) == 0 {
+		return res, withCode(exitUsage, fmt.Errorf("filnamn för pdf måste anges"))
+	}
+	res.File = args[0]
+	in, err := readInput(args[0], f.format)
+	if err != nil {
+		return res, withCode(exitInput, fmt.Errorf("fel vid läsning av pdf: %w", err))
+	}
+
+	r, err := unionfees.Convert(context.Background(), in, opts)
+	res.Hash = r.Hash
+	res.Company = r.CompanyName
+	res.OrgNum = r.OrgNum
+	res.PayoutDate = r.PayoutDate.Format("2006-01-02")
+	res.Year = r.Year
+	res.Period = r.Period
+	res.AccountingType = int(r.Accounting)
+	if err != nil {
+		return res, convertError(err)
+	}
+
+	if f.print && r.Document != nil {
+		r.Document.Fprint(out)
+	}
+
+	fmt.Fprintf(out, "Företag: \t%s\n", r.CompanyName)
+	fmt.Fprintf(out, "Orgnr:   \t%s\n", r.OrgNum)
+	fmt.Fprintf(out, "Utb. datum: \t%s\n", f.date)
+	fmt.Fprintf(out, "År:     \t%d\n", r.Year)
+	fmt.Fprintf(out, "Månad:     \t%d\n", r.Period)
+	fmt.Fprintf(out, "Typ:     \t%s\n", r.Accounting)
+	for _, d := range r.Diagnostics {
+		if d.Union == "" {
+			res.warn(out, d.Message)
+		}
+	}
+
+	// files to write once all unions are converted, so that -stdout can
+	// refuse more than one file before anything is written
+	type pendingFile struct {
+		file  unionfees.File
+		union int // index in res.Unions
+	}
+	var pending []pendingFile
+	var lists []xlsx.Members
+
+	for _, u := range r.Unions {
+		fmt.Fprintln(out, u.Name)
+		for _, d := range r.Diagnostics {
+			if d.Union == u.Name {
+				res.warn(out, d.Message)
+			}
+		}
+		lists = append(lists, xlsx.Members{Union: u.Name, S2: u.S2})
+
+		ur := unionResult{Name: u.Name, Code: u.Code, Records: u.Records, Sum: u.Sum, Locations: []locationResult{}, Files: []string{}}
+		for _, locnum := range slices.Sorted(maps.Keys(u.Locations)) {
+			l := u.Locations[locnum]
+			fmt.Fprintf(out, "Plats %d, Antal: %d, Summa: %s\n", l.S3.LocNum, len(l.S2), l.S3.SumAmout)
+			ur.Locations = append(ur.Locations, locationResult{LocNum: locnum, Records: l.S3.Records, Sum: l.S3.SumAmout})
+		}
+		res.Unions = append(res.Unions, ur)
+		idx := len(res.Unions) - 1
+
+		for _, file := range u.Files {
+			filename := filepath.Join(outDir, file.Name)
+			code := union.UnionCode(file.Code)
+			switch {
+			case f.explain:
+				fmt.Fprintf(out, "\nFörklaring av '%s'\n\n", filename)
+				if err := union.Explain(out, file.Locations, code); err != nil {
+					return res, withCode(exitWrite, err)
+				}
+				res.Unions[idx].Files = append(res.Unions[idx].Files, filename)
+			case f.dryRun:
+				fmt.Fprintf(out, "\nFörhandsvisning av '%s'\n\n", filename)
+				if _, err := union.Preview(out, file.Locations, code); err != nil {
+					return res, withCode(exitWrite, err)
+				}
+				res.Unions[idx].Files = append(res.Unions[idx].Files, filename)
+			default:
+				pending = append(pending, pendingFile{file: file, union: idx})
+			}
+		}
+	}
+
+	write := func() error {
+		switch {
+		case f.xlsx != "":
+			err := output.WriteFile(f.xlsx, f.force, func(w io.Writer) error {
+				return xlsx.WriteMembers(w, lists)
+			})
+			if errors.Is(err, output.ErrExists) {
+				return withCode(exitExists, fmt.Errorf("filen '%s' finns redan, ange -f för att skriva över", f.xlsx))
+			}
+			if err != nil {
+				return withCode(exitWrite, fmt.Errorf("error writing %s: %w", f.xlsx, err))
+			}
+			for i := range res.Unions {
+				res.Unions[i].Files = append(res.Unions[i].Files, f.xlsx)
+			}
+			fmt.Fprintf(out, "\nMedlemslistorna är skrivna till '%s', läs in dem igen med convert när de är granskade\n", f.xlsx)
+		case f.stdout:
+			if len(pending) != 1 {
+				return withCode(exitUsage, fmt.Errorf("-stdout ger %d filer, välj ett förbund med -forbund eller använd -zip", len(pending)))
+			}
+			p := pending[0]
+			if _, err := f.stream.Write(p.file.Data); err != nil {
+				return withCode(exitWrite, fmt.Errorf("error writing to stdout: %w", err))
+			}
+			res.Unions[p.union].Files = append(res.Unions[p.union].Files, p.file.Name)
+			fmt.Fprintf(out, "\nFilen '%s' är skriven till stdout\n", p.file.Name)
+		case f.zip:
+			zf, err := output.NewZipFile(f.stream)
+			if err != nil {
+				return withCode(exitWrite, fmt.Errorf("error creating zip: %w", err))
+			}
+			for _, p := range pending {
+				if _, err := zf.AddFile(p.file.Name, bytes.NewReader(p.file.Data)); err != nil {
+					return withCode(exitWrite, fmt.Errorf("error writing %s to zip: %w", p.file.Name, err))
+				}
+				res.Unions[p.union].Files = append(res.Unions[p.union].Files, p.file.Name)
+				fmt.Fprintf(out, "\nFilen '%s' är tillagd i zip-arkivet\n", p.file.Name)
+			}
+			if err := zf.Close(); err != nil {
+				return withCode(exitWrite, fmt.Errorf("error writing zip: %w", err))
+			}
+		default:
+			// all files or none, a run that fails leaves no partial output
+			files := make([]output.File, len(pending))
+			for i, p := range pending {
+				files[i] = output.File{Path: filepath.Join(outDir, p.file.Name), Data: p.file.Data}
+			}
+			err := output.WriteFiles(files, f.force)
+			var pe *fs.PathError
+			if errors.As(err, &pe) && errors.Is(err, output.ErrExists) {
+				return withCode(exitExists, fmt.Errorf("filen '%s' finns redan, ange -f för att skriva över", pe.Path))
+			}
+			if errors.As(err, &pe) {
+				return withCode(exitWrite, fmt.Errorf("error writing %s: %w", pe.Path, pe.Err))
+			}
+			if err != nil {
+				return withCode(exitWrite, err)
+			}
+			for i, p := range pending {
+				res.Unions[p.union].Files = append(res.Unions[p.union].Files, files[i].Path)
+				fmt.Fprintf(out, "\nFilen '%s' är skapad\n", files[i].Path)
+			}
+		}
+		return nil
+	}
+
+	// the history is checked and the files written as one step, so that
+	// two conversions of the same report warn about each other
+	hist := openHistory(cfg)
+	if hist == nil {
+		return res, write()
+	}
+	var werr error
+	err = hist.Record(historyEntry(r, f.test), func(matches []history.Match, err error) bool {
+		if err != nil {
+			res.warn(out, fmt.Sprintf("historiken kunde inte läsas: %v", err))
+		}
+		for _, m := range matches {
+			res.warn(out, m.String())
+		}
+		werr = write()
+		return werr == nil && f.xlsx == "" && len(pending) > 0
+	})
+	if werr != nil {
+		return res, werr
+	}
+	if err != nil {
+		res.warn(out, fmt.Sprintf("historiken kunde inte sparas: %v", err))
+	}
+	return res, nil
+}
+
+// convertError gives errors from unionfees.Convert their exit code
+func convertError(err error) error {
+	var (
+		pe *unionfees.ParseError
+		ve *unionfees.ValidationError
+		ee *unionfees.EncodingError
+		oe *unionfees.FormatOverflowError
+	)
+	switch {
+	case errors.As(err, &pe):
+		return withCode(exitInput, err)
+	case errors.As(err, &ve), errors.As(err, &ee), errors.As(err, &oe):
+		return withCode(exitData, err)
+	case errors.Is(err, unionfees.ErrConfig):
+		return withCode(exitConfig, err)
+	case errors.Is(err, unionfees.ErrOptions):
+		return withCode(exitUsage, err)
+	case errors.Is(err, unionfees.ErrInput):
+		return withCode(exitInput, err)
+	case errors.Is(err, unionfees.ErrPeriod):
+		return withCode(exitPeriod, err)
+	}
+	return err
+}
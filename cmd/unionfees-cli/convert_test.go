@@ -0,0 +1,171 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kmpm/unionfees/internal"
+	"github.com/kmpm/unionfees/internal/pdftest"
+	"github.com/kmpm/unionfees/internal/union"
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/shopspring/decimal"
+)
+
+// convertDir writes a report for the current period and an empty config
+// to a temporary directory, with the history in the same directory
+func convertDir(t *testing.T) (dir, pdf, cfg string) {
+	t.Helper()
+	dir = t.TempDir()
+	t.Setenv("UNIONFEES_HISTORY", filepath.Join(dir, "historik.jsonl"))
+	cfg = filepath.Join(dir, "unionfees.toml")
+	if err := os.WriteFile(cfg, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := pdftest.Example()
+	r.Period = time.Now().Format("2006-01")
+	pdf = filepath.Join(dir, "fackavgifter.pdf")
+	if err := pdftest.WriteFile(pdf, r); err != nil {
+		t.Fatal(err)
+	}
+	return dir, pdf, cfg
+}
+
+func TestRunConvertConcurrentDuplicate(t *testing.T) {
+	dir, pdf, cfg := convertDir(t)
+
+	var wg sync.WaitGroup
+	results := make([]*convertResult, 2)
+	for i := range results {
+		f := &convertFlags{
+			date:   time.Now().Format("060102"),
+			outDir: filepath.Join(dir, "ut", string(rune('a'+i))),
+			config: cfg,
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := runConvert(f, []string{pdf}, io.Discard)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+
+	warned := 0
+	for _, res := range results {
+		if res != nil && strings.Contains(strings.Join(res.Warnings, "\n"), "har redan gjorts om") {
+			warned++
+		}
+	}
+	if warned != 1 {
+		t.Errorf("%d of the conversions warned about the other, want 1", warned)
+	}
+}
+
+func TestRunConvertNoPartialOutput(t *testing.T) {
+	dir, pdf, cfg := convertDir(t)
+	outDir := filepath.Join(dir, "ut")
+	if err := os.Mkdir(outDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// the file of the second union exists
+	period := time.Now().Format("0601")
+	existing := filepath.Join(outDir, "IF Metall-"+period+".txt")
+	if err := os.WriteFile(existing, []byte("skickad"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &convertFlags{date: time.Now().Format("060102"), outDir: outDir, config: cfg}
+	_, err := runConvert(f, []string{pdf}, io.Discard)
+	if exitCode(err) != exitExists || !strings.Contains(err.Error(), existing) {
+		t.Fatalf("runConvert() error = %v, want that %s exists", err, existing)
+	}
+	entries, _ := os.ReadDir(outDir)
+	if len(entries) != 1 {
+		t.Errorf("output = %v, want only the existing file", entries)
+	}
+
+	f.force = true
+	if _, err := runConvert(f, []string{pdf}, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(outDir); len(entries) != 2 {
+		t.Errorf("output with -f = %v, want a file per union", entries)
+	}
+}
+
+func TestRunConvertCorrectionAndSupplement(t *testing.T) {
+	dir, pdf, cfg := convertDir(t)
+	f := &convertFlags{date: time.Now().Format("060102"), outDir: filepath.Join(dir, "ut"), config: cfg, correct: true, supp: true}
+	_, err := runConvert(f, []string{pdf}, io.Discard)
+	if exitCode(err) != exitUsage {
+		t.Errorf("runConvert() error = %v, want a usage error", err)
+	}
+	if _, err := os.Stat(f.outDir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("output was written: %v", err)
+	}
+}
+
+// TestRunConvertOriginalEncoding reads an original written in the
+// encoding of the config, with a member that has left since
+func TestRunConvertOriginalEncoding(t *testing.T) {
+	dir, pdf, cfg := convertDir(t)
+	if err := os.WriteFile(cfg, []byte("[output]\nencoding = \"iso-8859-1\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// a character that windows-1252 reads as Š, which iso-8859-1 can
+	// not write back
+	left := "L\u008aUND"
+	now := time.Now()
+	locs := internal.BuildLocations(internal.CompanyArgs{
+		CompanyNum: 5566778899, CompanyName: "EXEMPEL AB", Period: int(now.Month()), Year: now.Year() - 2000, TransactionDate: now,
+	}, []spec.S2Spec{{LocNum: 1, PersonNum: 1212121212, Name: left, Amount: decimal.RequireFromString("100.00"), PayCode: spec.PayCodeAmountPayed}})
+	enc, _ := union.Encoding("iso-8859-1")
+	var buf bytes.Buffer
+	if err := union.WriteTableEncoding(&buf, locs, union.CodeIFMetall, enc); err != nil {
+		t.Fatal(err)
+	}
+	orig := filepath.Join(dir, "original.txt")
+	if err := os.WriteFile(orig, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &convertFlags{
+		date: now.Format("060102"), outDir: filepath.Join(dir, "ut"), config: cfg,
+		correct: true, orig: orig, unions: "IF Metall",
+	}
+	res, err := runConvert(f, []string{pdf}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Unions) != 1 || len(res.Unions[0].Files) != 1 {
+		t.Fatalf("unions = %+v, want one file", res.Unions)
+	}
+	got, _, err := readOriginal(res.Unions[0].Files[0], "iso-8859-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, s2 := range got {
+		if s2.PersonNum == 1212121212 {
+			found = strings.TrimSpace(s2.Name) == left && s2.Amount.IsZero()
+		}
+	}
+	if !found {
+		t.Errorf("correction = %+v, want %s with amount 0", got, left)
+	}
+}
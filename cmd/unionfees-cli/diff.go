@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/kmpm/unionfees/internal"
+	"github.com/kmpm/unionfees/public/unionfees"
+)
+
+func newDiffCmd() *command {
+	var cfgPath string
+	c := newCommand("diff", "<gammal.txt> <ny.txt>", "Visa skillnader per medlem mellan två filer för fackförbundet")
+	c.flags.StringVar(&cfgPath, "config", "", "konfigurationsfil, för teckenkodningen")
+	c.run = func(args []string) error {
+		if len(args) != 2 {
+			c.usage()
+			return fmt.Errorf("två filer måste anges")
+		}
+		cfg, err := unionfees.LoadConfig(cfgPath)
+		if err != nil {
+			return withCode(exitConfig, err)
+		}
+		a, _, err := readOriginal(args[0], cfg.Output.Encoding)
+		if err != nil {
+			return fmt.Errorf("fel vid läsning av '%s': %w", args[0], err)
+		}
+		b, _, err := readOriginal(args[1], cfg.Output.Encoding)
+		if err != nil {
+			return fmt.Errorf("fel vid läsning av '%s': %w", args[1], err)
+		}
+		changes := internal.Diff(a, b)
+		for _, ch := range changes {
+			switch {
+			case ch.New == nil:
+				fmt.Printf("- %04d %010d %-24s %10s\n", ch.Old.LocNum, ch.Old.PersonNum, ch.Old.Name, ch.Old.Amount.StringFixed(2))
+			case ch.Old == nil:
+				fmt.Printf("+ %04d %010d %-24s %10s\n", ch.New.LocNum, ch.New.PersonNum, ch.New.Name, ch.New.Amount.StringFixed(2))
+			default:
+				fmt.Printf("~ %04d %010d %-24s %10s -> %s (kod %d -> %d)\n", ch.New.LocNum, ch.New.PersonNum, ch.New.Name,
+					ch.Old.Amount.StringFixed(2), ch.New.Amount.StringFixed(2), ch.Old.PayCode, ch.New.PayCode)
+			}
+		}
+		fmt.Printf("%d skillnader\n", len(changes))
+		return nil
+	}
+	return c
+}
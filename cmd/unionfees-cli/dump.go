@@ -0,0 +1,54 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+func newDumpCmd() *command {
+	var table, format string
+	var asJSON bool
+	c := newCommand("dump", "<filename.pdf>", "Skriv ut tabellerna i pdf-filen semikolonseparerade")
+	c.flags.StringVar(&table, "t", "", "visa bara förbund vars namn innehåller texten")
+	c.flags.StringVar(&format, "format", "", "indataformat, pdf, xlsx, visma-csv eller csv (default känns igen)")
+	c.flags.BoolVar(&asJSON, "json", false, "skriv pdf:ens textlager som json, kan läsas i stället för pdf:en av alla kommandon")
+	c.run = func(args []string) error {
+		if len(args) != 1 {
+			c.usage()
+			return fmt.Errorf("filnamn för pdf måste anges")
+		}
+		rep, err := loadReport(args[0], "", "", format, nil)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			doc, err := rep.document("-json")
+			if err != nil {
+				return err
+			}
+			return doc.Save(os.Stdout)
+		}
+		tables := rep.cells()
+		names := make([]string, 0, len(tables))
+		for k := range tables {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			if !strings.Contains(k, table) {
+				continue
+			}
+			for _, row := range tables[k] {
+				fmt.Printf("%s;%s\n", k, strings.Join(row, ";"))
+			}
+		}
+		return nil
+	}
+	return c
+}
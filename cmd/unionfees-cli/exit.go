@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import "errors"
+
+// Exit codes are part of the interface for scripts wrapping the cli,
+// do not renumber them.
+const (
+	exitOK      = 0
+	exitFailure = 1 // unclassified error
+	exitUsage   = 2 // bad flags or arguments
+	exitInput   = 3 // pdf or union file could not be read or parsed
+	exitPeriod  = 4 // date or period not accepted
+	exitExists  = 5 // output file exists and -f was not given
+	exitWrite   = 6 // output could not be written
+	exitConfig  = 7 // configuration file is invalid
+	exitData    = 8 // records are invalid or do not fit the file format
+)
+
+// errReported wraps errors that have already been shown to the user,
+// e.g. as part of the json output
+var errReported = errors.New("reported")
+
+// exitError carries the exit code for an error
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+func withCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// exitCode returns the code to exit with for err
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var ee *exitError
+	if errors.As(err, &ee) {
+		return ee.code
+	}
+	return exitFailure
+}
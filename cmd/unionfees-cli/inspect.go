@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/kmpm/unionfees/internal/input"
+	"github.com/kmpm/unionfees/internal/output"
+	"github.com/kmpm/unionfees/internal/parser"
+)
+
+func newInspectCmd() *command {
+	var rows, asJSON bool
+	var svgDir, layoutDir, format string
+	c := newCommand("inspect", "<filename.pdf>", "Visa hur pdf-filen tolkas, utan att skapa några filer")
+	c.debugPDF = func() bool { return !asJSON }
+	c.flags.BoolVar(&rows, "rows", true, "visa alla rader i dokumentet")
+	c.flags.BoolVar(&asJSON, "json", false, "skriv alla textfragment med position, typsnitt och storlek som json")
+	c.flags.StringVar(&svgDir, "svg", "", "rita varje sida som sida-N.svg i katalogen, med rader, celler, kolumner och tabeller")
+	c.flags.StringVar(&format, "format", "", "indataformat, pdf, xlsx, visma-csv eller csv (default känns igen)")
+	c.flags.StringVar(&layoutDir, "mallar", "", "katalog med egna rapportmallar att prova utöver de inbyggda")
+	c.run = func(args []string) error {
+		if len(args) != 1 {
+			c.usage()
+			return fmt.Errorf("filnamn för pdf måste anges")
+		}
+		var layouts []*parser.Template
+		if layoutDir != "" {
+			var err error
+			if layouts, err = parser.LoadTemplates(layoutDir); err != nil {
+				return withCode(exitConfig, err)
+			}
+		}
+		rep, err := loadReport(args[0], "", "", format, input.Adapters(layouts, nil))
+		if err != nil {
+			return err
+		}
+		if svgDir != "" {
+			doc, err := rep.document("-svg")
+			if err != nil {
+				return err
+			}
+			if err := writeSVGs(svgDir, doc); err != nil {
+				return withCode(exitWrite, err)
+			}
+		}
+		if asJSON {
+			doc, err := rep.document("-json")
+			if err != nil {
+				return err
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(doc.Layout())
+		}
+		if rows && rep.Doc != nil {
+			rep.Doc.Fprint(os.Stdout)
+		}
+		fmt.Printf("Företag: \t%s\n", rep.CompanyName)
+		fmt.Printf("Orgnr:   \t%s\n", rep.OrgNum)
+		fmt.Printf("Format:  \t%s\n", rep.Format)
+		if rep.Doc != nil {
+			fmt.Printf("Sidor:   \t%d\n", len(rep.Doc.Pages))
+			fmt.Printf("Mall:    \t%s, %s\n", rep.Doc.Template.Name, rep.Doc.Template.Description)
+		}
+
+		tables := rep.cells()
+		names := make([]string, 0, len(tables))
+		for k := range tables {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			fmt.Printf("Förbund: \t%s, %d rader\n", k, len(tables[k]))
+		}
+		return nil
+	}
+	return c
+}
+
+// writeSVGs draws every page of doc into dir
+func writeSVGs(dir string, doc *parser.Document) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, pl := range doc.Layout() {
+		filename := filepath.Join(dir, fmt.Sprintf("sida-%d.svg", pl.Page))
+		err := output.WriteFile(filename, true, func(w io.Writer) error {
+			return parser.WriteSVG(w, pl)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Sidan %d är ritad i '%s'\n", pl.Page, filename)
+	}
+	return nil
+}
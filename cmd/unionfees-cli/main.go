@@ -5,53 +5,45 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"log"
 	"os"
-	"time"
+	"path/filepath"
+	"slices"
+	"strings"
 
-	"github.com/kmpm/unionfees/internal"
-	"github.com/kmpm/unionfees/internal/parser"
-	"github.com/kmpm/unionfees/internal/union"
+	"github.com/kmpm/unionfees/internal/input"
 	"github.com/ledongthuc/pdf"
 )
 
-var (
-	flagNum     string
-	flagName    string
-	flagPeriod  int
-	flagYear    int
-	flagDate    string
-	flagPrint   bool
-	flagVersion bool
-)
-
 var appVersion = "v0.0.0-dev"
 
-func init() {
-	flag.StringVar(&flagNum, "o", "", "organisationsnummer (default från pdf)")
-	flag.StringVar(&flagName, "n", "", "företagsnamn (default från pdf)")
-	flag.IntVar(&flagPeriod, "m", 0, "redovisningsperiod MM (default från datum)")
-	flag.IntVar(&flagYear, "y", 0, "redovisningår ÅÅ (default från datum)")
-	flag.StringVar(&flagDate, "d", "", "utbetalningsdatum ÅÅMMDD")
-	flag.BoolVar(&flagPrint, "print", false, "Visa det tolkade dokumentet")
-	flag.BoolVar(&flagVersion, "version", false, "Visa versionsnummer och avsluta")
+// command is a subcommand with its own flags and help text
+type command struct {
+	name  string
+	short string
+	args  string
+	flags *flag.FlagSet
+	run   func(args []string) error
+	// debugPDF, if set, reports after the flags are parsed if the pdf
+	// library should print what it does not understand. It is a global so
+	// it is set before the command runs, and it must be off when the
+	// command writes json or tables to stdout as the library prints some
+	// of it there.
+	debugPDF func() bool
 }
 
-func usage() {
-	fmt.Fprintf(os.Stderr, "Usage of %s:\n [flags] <filename.pdf>", os.Args[0])
-	fmt.Fprint(os.Stderr, "\nFlags\n")
-	flag.PrintDefaults()
+func (c *command) usage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s %s:\n  %s [flags] %s\n", os.Args[0], c.name, c.name, c.args)
+	fmt.Fprintf(os.Stderr, "\n%s\n\nFlags\n", c.short)
+	c.flags.PrintDefaults()
 }
 
-func printVersion() {
-	fmt.Printf("Version %s", appVersion)
-}
-
-func isFlagPassed(name string) bool {
+// isFlagPassed reports if name was given on the command line
+func (c *command) isFlagPassed(name string) bool {
 	found := false
-	flag.Visit(func(f *flag.Flag) {
+	c.flags.Visit(func(f *flag.Flag) {
 		if f.Name == name {
 			found = true
 		}
@@ -59,120 +51,127 @@ func isFlagPassed(name string) bool {
 	return found
 }
 
-func main() {
-	flag.Usage = usage
-	flag.Parse()
-
-	if flagVersion {
-		printVersion()
-		os.Exit(0)
-	}
-
-	t, err := time.Parse("060102", flagDate)
-	if err != nil {
-		fmt.Printf("Felaktigt datum: %v\n", err)
-		flag.Usage()
-		os.Exit(1)
-	}
-	now := time.Now()
-	if t.Year() < now.Year() {
-		fmt.Printf("Felaktigt år i datum: %d < %d\n", t.Year(), now.Year())
-		flag.Usage()
-		os.Exit(1)
-	}
-
-	if !isFlagPassed("m") {
-		flagPeriod = int(t.Month())
-	}
-
-	if flagPeriod < 1 || flagPeriod > 12 {
-		fmt.Printf("Felaktig period/månad: %d\n", flagPeriod)
-		flag.Usage()
-		os.Exit(1)
+// parse parses flags, also when they come after positional arguments,
+// and returns the positional arguments
+func (c *command) parse(args []string) ([]string, error) {
+	var pos []string
+	for {
+		if err := c.flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = c.flags.Args()
+		if len(args) == 0 {
+			return pos, nil
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
 	}
+}
 
-	if !isFlagPassed("y") {
-		flagYear = t.Year() - 2000
+// newCommand creates a command, callers set run after adding their flags
+func newCommand(name, args, short string) *command {
+	c := &command{
+		name:  name,
+		short: short,
+		args:  args,
+		flags: flag.NewFlagSet(name, flag.ContinueOnError),
 	}
+	c.flags.Usage = c.usage
+	return c
+}
 
-	if flagYear < 0 || flagYear > 99 || flagYear < (now.Year()-2000) {
-		fmt.Printf("Felaktigt år: %d\n", flagYear)
-		flag.Usage()
-		os.Exit(1)
+func commands() []*command {
+	return []*command{
+		newConvertCmd(),
+		newExplainCmd(),
+		newInspectCmd(),
+		newValidateCmd(),
+		newDiffCmd(),
+		newDumpCmd(),
+		newAnonymizeCmd(),
+		newWatchCmd(),
+		newBatchCmd(),
+		newWizardCmd(),
+		newConfigCmd(),
+		newVersionCmd(),
 	}
+}
 
-	pdf.DebugOn = true
-	filename := flag.Arg(0)
-	if filename == "" {
-		log.Fatal("filnamn för pdf måste anges")
-	}
-	doc, err := parser.ReadPdf(filename) // Read local pdf file
-	if err != nil {
-		log.Fatalf("fel vid läsning av pdf: %v", err)
+func usage(cmds []*command) {
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n  <command> [flags] [args]\n\nCommands\n", os.Args[0])
+	for _, c := range cmds {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.short)
 	}
+	fmt.Fprintf(os.Stderr, "\nUtan kommando körs convert, eller wizard om inga argument anges.\n")
+	fmt.Fprintf(os.Stderr, "%s <command> -h visar hjälp för ett kommando.\n", os.Args[0])
+}
 
-	if flagPrint {
-		doc.Fprint(os.Stdout)
+// route returns the command to run and its arguments. A first argument
+// that is not a command but a file, or has the extension of a file that
+// can be converted, runs convert.
+func route(cmds []*command, args []string, interactive bool) (string, []string) {
+	if len(args) == 0 {
+		if interactive {
+			// started without arguments, e.g. by double clicking
+			return "wizard", args
+		}
+		return "convert", args
 	}
-
-	matrix := doc.Pages[0].Strings()
-	if flagName == "" {
-		flagName = matrix[0][0]
+	switch {
+	case args[0] == "-version":
+		// kept for scripts using the old flag
+		return "version", args[1:]
+	case strings.HasPrefix(args[0], "-"):
+		return "convert", args
+	case slices.ContainsFunc(cmds, func(c *command) bool { return c.name == args[0] }):
+		return args[0], args[1:]
+	case isInputFile(args[0]):
+		return "convert", args
 	}
+	return args[0], args[1:]
+}
 
-	if len(flagName) < 3 {
-		fmt.Printf("Namn måste vara längre än 3 tecken")
-		os.Exit(1)
+// isInputFile tells if name is an existing file or has the extension of
+// an input format
+func isInputFile(name string) bool {
+	if slices.Contains(input.Extensions, strings.ToLower(filepath.Ext(name))) {
+		return true
 	}
-	fmt.Printf("Företag: \t%s\n", flagName)
+	info, err := os.Stat(name)
+	return err == nil && !info.IsDir()
+}
 
-	if flagNum == "" {
-		flagNum = matrix[1][0]
-	}
+func main() {
+	cmds := commands()
+	args := os.Args[1:]
 
-	cn, err := internal.Str2Person(flagNum)
-	if err != nil {
-		fmt.Printf("Felaktigt orgnr: %v\n", err)
-		os.Exit(1)
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "help") {
+		usage(cmds)
+		os.Exit(0)
 	}
+	name, args := route(cmds, args, isTerminal(os.Stdin))
 
-	fmt.Printf("Orgnr:   \t%s\n", flagNum)
-	fmt.Printf("Utb. datum: \t%s\n", flagDate)
-	fmt.Printf("År:     \t%d\n", flagYear)
-	fmt.Printf("Månad:     \t%d\n", flagPeriod)
-
-	for k, v := range doc.GetTables() {
-		fmt.Println(k)
-
-		listS2, err := internal.ConvertS2Data(1, v)
-		if err != nil {
-			log.Fatal("error generating S2 list", err)
-		}
-
-		locs := internal.BuildLocations(
-			internal.CompanyArgs{
-				CompanyNum:      cn,
-				CompanyName:     flagName,
-				Period:          flagPeriod,
-				Year:            flagYear,
-				TransactionDate: t,
-			},
-			listS2,
-		)
-		for _, l := range locs {
-			fmt.Printf("Plats %d, Antal: %d, Summa: %s\n", l.S3.LocNum, len(l.S2), l.S3.SumAmout)
+	for _, c := range cmds {
+		if c.name != name {
+			continue
 		}
-
-		filename := fmt.Sprintf("%s-%02d%02d.txt", k, flagYear, flagPeriod)
-		f, err := os.Create(filename)
+		pos, err := c.parse(args)
 		if err != nil {
-			log.Fatalf("error creating file %s: %v", filename, err)
+			if errors.Is(err, flag.ErrHelp) {
+				os.Exit(exitOK)
+			}
+			os.Exit(exitUsage)
 		}
-		defer f.Close()
-		err = union.WriteTable(f, locs, union.CodeIFMetall)
-		if err != nil {
-			log.Fatalf("error writing %s: %v", k, err)
+		pdf.DebugOn = c.debugPDF != nil && c.debugPDF()
+		if err := c.run(pos); err != nil {
+			if !errors.Is(err, errReported) {
+				fmt.Fprintf(os.Stderr, "Fel: %v\n", err)
+			}
+			os.Exit(exitCode(err))
 		}
-		fmt.Printf("\nFilen '%s' är skapad\n", filename)
+		return
 	}
+	fmt.Fprintf(os.Stderr, "Okänt kommando: %s\n\n", name)
+	usage(cmds)
+	os.Exit(exitUsage)
 }
@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRoute(t *testing.T) {
+	dir := t.TempDir()
+	export := filepath.Join(dir, "lönerapport")
+	if err := os.WriteFile(export, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmds := commands()
+	tests := []struct {
+		args        []string
+		interactive bool
+		name        string
+		rest        []string
+	}{
+		{nil, true, "wizard", nil},
+		{nil, false, "convert", nil},
+		{[]string{"rapport.pdf"}, false, "convert", []string{"rapport.pdf"}},
+		{[]string{"RAPPORT.PDF"}, false, "convert", []string{"RAPPORT.PDF"}},
+		{[]string{"rapport.csv", "-d", "250425"}, false, "convert", []string{"rapport.csv", "-d", "250425"}},
+		{[]string{"rapport.xlsx"}, false, "convert", []string{"rapport.xlsx"}},
+		{[]string{"rapport.skv"}, false, "convert", []string{"rapport.skv"}},
+		{[]string{export}, false, "convert", []string{export}},
+		{[]string{"-d", "250425", "rapport.csv"}, false, "convert", []string{"-d", "250425", "rapport.csv"}},
+		{[]string{"-"}, false, "convert", []string{"-"}},
+		{[]string{"-version"}, false, "version", []string{}},
+		{[]string{"batch", "in"}, false, "batch", []string{"in"}},
+		{[]string{"okänt"}, false, "okänt", []string{}},
+		{[]string{dir}, false, dir, []string{}},
+	}
+	for _, tt := range tests {
+		name, rest := route(cmds, tt.args, tt.interactive)
+		if name != tt.name || !slices.Equal(rest, tt.rest) {
+			t.Errorf("route(%q) = %q, %q, want %q, %q", tt.args, name, rest, tt.name, tt.rest)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// prompter asks questions on a terminal
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newPrompter(in io.Reader, out io.Writer) *prompter {
+	return &prompter{in: bufio.NewReader(in), out: out}
+}
+
+// ask returns the trimmed answer, io.EOF when input is closed
+func (p *prompter) ask(question string) (string, error) {
+	fmt.Fprintf(p.out, "%s: ", question)
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(p.out)
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// yesNo asks until the answer is j(a) or n(ej), empty gives def
+func (p *prompter) yesNo(question string, def bool) (bool, error) {
+	hint := "j/N"
+	if def {
+		hint = "J/n"
+	}
+	for {
+		a, err := p.ask(fmt.Sprintf("%s (%s)", question, hint))
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(a) {
+		case "":
+			return def, nil
+		case "j", "ja", "y", "yes":
+			return true, nil
+		case "n", "nej", "no":
+			return false, nil
+		}
+		fmt.Fprintln(p.out, "Svara j eller n")
+	}
+}
+
+// date asks for a date ÅÅÅÅ-MM-DD until a valid one is given
+func (p *prompter) date(question string) (time.Time, error) {
+	for {
+		a, err := p.ask(question + " ÅÅÅÅ-MM-DD")
+		if err != nil {
+			return time.Time{}, err
+		}
+		t, err := time.Parse("2006-01-02", a)
+		if err == nil {
+			return t, nil
+		}
+		fmt.Fprintln(p.out, "Inte giltigt datum")
+	}
+}
+
+// choose lets the user pick some of n numbered items,
+// as a comma separated list. Empty answer picks all if all is set.
+func (p *prompter) choose(question string, n int, all bool) ([]int, error) {
+	for {
+		a, err := p.ask(question)
+		if err != nil {
+			return nil, err
+		}
+		if a == "" && all {
+			picked := make([]int, n)
+			for i := range picked {
+				picked[i] = i
+			}
+			return picked, nil
+		}
+		picked, ok := parseChoice(a, n)
+		if ok {
+			return picked, nil
+		}
+		fmt.Fprintf(p.out, "Ange nummer mellan 1 och %d\n", n)
+	}
+}
+
+// parseChoice parses "1,3" into zero based indexes below n
+func parseChoice(a string, n int) ([]int, bool) {
+	picked := []int{}
+	for _, part := range strings.Split(a, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || i < 1 || i > n {
+			return nil, false
+		}
+		picked = append(picked, i-1)
+	}
+	return picked, len(picked) > 0
+}
@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseChoice(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want []int
+		ok   bool
+	}{
+		{"1", 3, []int{0}, true},
+		{"1,3", 3, []int{0, 2}, true},
+		{" 2 , 1 ", 3, []int{1, 0}, true},
+		{"", 3, nil, false},
+		{"0", 3, nil, false},
+		{"4", 3, nil, false},
+		{"1,", 3, nil, false},
+		{"a", 3, nil, false},
+		{"1-2", 3, nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseChoice(tt.in, tt.n)
+		if ok != tt.ok || !slices.Equal(got, tt.want) {
+			t.Errorf("parseChoice(%q, %d) = %v, %v, want %v, %v", tt.in, tt.n, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestYesNo(t *testing.T) {
+	tests := []struct {
+		script string
+		def    bool
+		want   bool
+		retry  bool // an invalid answer was given first
+		err    error
+	}{
+		{"j\n", false, true, false, nil},
+		{"Ja\n", false, true, false, nil},
+		{"y\n", false, true, false, nil},
+		{"n\n", true, false, false, nil},
+		{"NEJ\n", true, false, false, nil},
+		{"\n", true, true, false, nil},
+		{"\n", false, false, false, nil},
+		{"kanske\nj\n", false, true, true, nil},
+		{"j", false, true, false, nil}, // no newline at the end
+		{"", true, false, false, io.EOF},
+		{"kanske\n", true, false, true, io.EOF},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		got, err := newPrompter(strings.NewReader(tt.script), &out).yesNo("Fråga", tt.def)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("yesNo(%q, %v) = %v, %v, want %v, %v", tt.script, tt.def, got, err, tt.want, tt.err)
+		}
+		if retry := strings.Contains(out.String(), "Svara j eller n"); retry != tt.retry {
+			t.Errorf("yesNo(%q) asked again = %v, want %v", tt.script, retry, tt.retry)
+		}
+	}
+}
+
+func TestDatePrompt(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+		retry  int
+		err    error
+	}{
+		{"2025-03-25\n", "2025-03-25", 0, nil},
+		{"  2025-03-25  \n", "2025-03-25", 0, nil},
+		{"250325\n2025-03-25\n", "2025-03-25", 1, nil},
+		{"2025-02-30\n\n2025-02-28\n", "2025-02-28", 2, nil},
+		{"", "", 0, io.EOF},
+		{"igår\n", "", 1, io.EOF},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		got, err := newPrompter(strings.NewReader(tt.script), &out).date("Datum")
+		var want time.Time
+		if tt.want != "" {
+			want, _ = time.Parse("2006-01-02", tt.want)
+		}
+		if !got.Equal(want) || !errors.Is(err, tt.err) {
+			t.Errorf("date(%q) = %v, %v, want %v, %v", tt.script, got, err, want, tt.err)
+		}
+		if n := strings.Count(out.String(), "Inte giltigt datum"); n != tt.retry {
+			t.Errorf("date(%q) asked again %d times, want %d", tt.script, n, tt.retry)
+		}
+	}
+}
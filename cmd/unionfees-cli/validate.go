@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/kmpm/unionfees/internal/union"
+	"github.com/kmpm/unionfees/public/unionfees"
+)
+
+func newValidateCmd() *command {
+	var cfgPath string
+	c := newCommand("validate", "<fil.txt>...", "Kontrollera en eller flera filer för fackförbundet")
+	c.flags.StringVar(&cfgPath, "config", "", "konfigurationsfil, för teckenkodningen")
+	c.run = func(args []string) error {
+		if len(args) == 0 {
+			c.usage()
+			return fmt.Errorf("minst en fil måste anges")
+		}
+		cfg, err := unionfees.LoadConfig(cfgPath)
+		if err != nil {
+			return withCode(exitConfig, err)
+		}
+		failed := 0
+		for _, filename := range args {
+			locs, code, err := readUnionFile(filename, cfg.Output.Encoding)
+			if err == nil {
+				err = union.Validate(locs)
+			}
+			if err != nil {
+				failed++
+				fmt.Printf("%s: FEL\n%v\n", filename, err)
+				continue
+			}
+			fmt.Printf("%s: OK, %s, %d platser\n", filename, code, len(locs))
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d av %d filer är felaktiga", failed, len(args))
+		}
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,16 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import "fmt"
+
+func newVersionCmd() *command {
+	c := newCommand("version", "", "Visa versionsnummer och avsluta")
+	c.run = func(args []string) error {
+		fmt.Printf("Version %s\n", appVersion)
+		return nil
+	}
+	return c
+}
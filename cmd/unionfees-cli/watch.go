@@ -0,0 +1,249 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kmpm/unionfees/internal"
+)
+
+type watchFlags struct {
+	date       string
+	interval   time.Duration
+	once       bool
+	archive    string
+	quarantine string
+	force      bool
+	test       bool
+	tmpl       string
+	maxAge     int
+	config     string
+}
+
+// reFileDate finds ÅÅÅÅ-MM-DD or ÅÅMMDD in a file name
+var reFileDate = regexp.MustCompile(`(20\d\d-[01]\d-[0-3]\d)|(?:^|[^\d])(\d{6})(?:[^\d]|$)`)
+
+func newWatchCmd() *command {
+	var f watchFlags
+	c := newCommand("watch", "<katalog>", "Bevaka en katalog och gör om nya pdf-filer")
+	c.flags.StringVar(&f.date, "d", "", "utbetalningsdatum ÅÅMMDD (default från filnamnet, annars frågas)")
+	c.flags.DurationVar(&f.interval, "intervall", 5*time.Second, "hur ofta katalogen kontrolleras")
+	c.flags.BoolVar(&f.once, "once", false, "gå igenom katalogen en gång och avsluta")
+	c.flags.StringVar(&f.archive, "arkiv", "", "katalog för behandlade pdf-filer (default <katalog>/arkiv)")
+	c.flags.StringVar(&f.quarantine, "karantan", "", "katalog för pdf-filer som inte kunde behandlas (default <katalog>/karantan)")
+	c.flags.BoolVar(&f.force, "f", false, "skriv över befintliga filer")
+	c.flags.BoolVar(&f.test, "test", false, "filerna är för en testinskickning")
+	c.flags.StringVar(&f.tmpl, "filnamn", "", "mall för filnamn, se convert -h")
+	c.flags.IntVar(&f.maxAge, "maxage", internal.DefaultPeriodPolicy.MaxAge, "hur många månader bakåt en rättelse får avse")
+	c.flags.StringVar(&f.config, "config", "", "konfigurationsfil")
+	c.run = func(args []string) error {
+		dir := "."
+		if len(args) > 1 {
+			return withCode(exitUsage, fmt.Errorf("bara en katalog kan bevakas"))
+		}
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		if f.archive == "" {
+			f.archive = filepath.Join(dir, "arkiv")
+		}
+		if f.quarantine == "" {
+			f.quarantine = filepath.Join(dir, "karantan")
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		w := &watcher{dir: dir, flags: f, sizes: map[string]int64{}, in: newPrompter(os.Stdin, os.Stdout)}
+		return w.run(ctx)
+	}
+	return c
+}
+
+type watcher struct {
+	dir   string
+	flags watchFlags
+	// sizes from the previous poll, a file is processed when its size is unchanged
+	sizes map[string]int64
+	in    *prompter
+}
+
+func (w *watcher) run(ctx context.Context) error {
+	for _, d := range []string{w.flags.archive, w.flags.quarantine} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			return withCode(exitWrite, err)
+		}
+	}
+	fmt.Printf("Bevakar '%s', avsluta med Ctrl+C\n", w.dir)
+	for {
+		if err := w.poll(); err != nil {
+			return err
+		}
+		if w.flags.once {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			fmt.Println("Klar")
+			return nil
+		case <-time.After(w.flags.interval):
+		}
+	}
+}
+
+// poll processes all pdf files in the directory whose size is stable
+func (w *watcher) poll() error {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return withCode(exitInput, err)
+	}
+	sizes := map[string]int64{}
+	names := []string{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".pdf") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		sizes[e.Name()] = info.Size()
+		prev, seen := w.sizes[e.Name()]
+		// with -once there is no second poll, so take everything
+		if w.flags.once || (seen && prev == info.Size()) {
+			names = append(names, e.Name())
+		}
+	}
+	w.sizes = sizes
+	sort.Strings(names)
+	for _, name := range names {
+		w.process(name)
+		delete(w.sizes, name)
+	}
+	return nil
+}
+
+func (w *watcher) process(name string) {
+	path := filepath.Join(w.dir, name)
+	fmt.Printf("Bearbetar %s\n", path)
+
+	date, err := w.payoutDate(name)
+	var res *convertResult
+	if err == nil {
+		f := convertFlags{
+			date:   date,
+			maxAge: w.flags.maxAge,
+			outDir: w.dir,
+			tmpl:   w.flags.tmpl,
+			force:  w.flags.force,
+			test:   w.flags.test,
+			config: w.flags.config,
+		}
+		res, err = runConvert(&f, []string{path}, os.Stdout)
+	}
+
+	target := w.flags.archive
+	status := "OK"
+	if err != nil {
+		target = w.flags.quarantine
+		status = "FEL"
+		fmt.Printf("Fel: %v\n", err)
+	}
+	dest, merr := moveFile(path, target)
+	if merr != nil {
+		fmt.Printf("Kunde inte flytta %s: %v\n", path, merr)
+		status = "FEL"
+		err = errors.Join(err, merr)
+	} else {
+		fmt.Printf("Flyttar %s till %s\n", name, dest)
+	}
+	w.log(name, dest, status, res, err)
+}
+
+// payoutDate returns the date flag, a date from the file name or asks for one
+func (w *watcher) payoutDate(name string) (string, error) {
+	if w.flags.date != "" {
+		return w.flags.date, nil
+	}
+	if d, ok := dateFromName(name); ok {
+		return d, nil
+	}
+	if !isTerminal(os.Stdin) {
+		return "", withCode(exitUsage, fmt.Errorf("utbetalningsdatum saknas i filnamnet och -d är inte angivet"))
+	}
+	t, err := w.in.date(fmt.Sprintf("Ange utbetalningsdatum för %s", name))
+	if err != nil {
+		return "", withCode(exitUsage, fmt.Errorf("inget datum angivet"))
+	}
+	return t.Format("060102"), nil
+}
+
+// dateFromName returns ÅÅMMDD if the name contains a valid date
+func dateFromName(name string) (string, bool) {
+	m := reFileDate.FindStringSubmatch(name)
+	if m == nil {
+		return "", false
+	}
+	if m[1] != "" {
+		if t, err := time.Parse("2006-01-02", m[1]); err == nil {
+			return t.Format("060102"), true
+		}
+		return "", false
+	}
+	if _, err := time.Parse("060102", m[2]); err == nil {
+		return m[2], true
+	}
+	return "", false
+}
+
+// moveFile moves path into dir, adding a timestamp if the name is taken
+func moveFile(path, dir string) (string, error) {
+	dest := filepath.Join(dir, filepath.Base(path))
+	if _, err := os.Stat(dest); err == nil {
+		ext := filepath.Ext(dest)
+		dest = fmt.Sprintf("%s-%s%s", strings.TrimSuffix(dest, ext), time.Now().Format("20060102-150405"), ext)
+	}
+	return dest, os.Rename(path, dest)
+}
+
+// log appends a line per processed file to process.log in the archive
+func (w *watcher) log(name, dest, status string, res *convertResult, err error) {
+	lf, ferr := os.OpenFile(filepath.Join(w.flags.archive, "process.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if ferr != nil {
+		fmt.Printf("Kunde inte skriva logg: %v\n", ferr)
+		return
+	}
+	defer lf.Close()
+
+	files := []string{}
+	if res != nil {
+		for _, u := range res.Unions {
+			files = append(files, u.Files...)
+		}
+	}
+	msg := ""
+	if err != nil {
+		msg = strings.ReplaceAll(err.Error(), "\n", " ")
+	}
+	fmt.Fprintf(lf, "%s\t%s\t%s\t%s\t%s\t%s\n",
+		time.Now().Format(time.RFC3339), status, name, dest, strings.Join(files, ","), msg)
+}
+
+// isTerminal reports if f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
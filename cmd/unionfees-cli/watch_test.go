@@ -0,0 +1,171 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kmpm/unionfees/internal/pdftest"
+)
+
+func TestDateFromName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"fackavgifter-2025-04-25.pdf", "250425", true},
+		{"2025-04-25 fackavgifter.pdf", "250425", true},
+		{"fackavgifter-250425.pdf", "250425", true},
+		{"250425.pdf", "250425", true},
+		{"fackavgifter_250425_v2.pdf", "250425", true},
+		{"fackavgifter.pdf", "", false},
+		{"fackavgifter-2025-02-30.pdf", "", false},
+		{"fackavgifter-251325.pdf", "", false},
+		{"fackavgifter-20250425.pdf", "", false}, // 8 digits is not ÅÅMMDD
+		{"fackavgifter-25042.pdf", "", false},
+		{"rapport2504251.pdf", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := dateFromName(tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("dateFromName(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "arkiv")
+	if err := os.Mkdir(dest, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for i, content := range []string{"första", "andra"} {
+		path := filepath.Join(dir, "rapport.pdf")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := moveFile(path, dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 && got != filepath.Join(dest, "rapport.pdf") {
+			t.Errorf("moveFile() = %q, want the same name", got)
+		}
+		if i == 1 && (!strings.HasPrefix(filepath.Base(got), "rapport-") || filepath.Ext(got) != ".pdf") {
+			t.Errorf("moveFile() = %q, want a time stamp added", got)
+		}
+		if data, err := os.ReadFile(got); err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v, want %q", got, data, err, content)
+		}
+	}
+}
+
+func TestWatchOnce(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("UNIONFEES_HISTORY", filepath.Join(dir, "historik.jsonl"))
+	cfg := filepath.Join(dir, "unionfees.toml")
+	if err := os.WriteFile(cfg, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	in := filepath.Join(dir, "in")
+	if err := os.Mkdir(in, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	r := pdftest.Example()
+	r.Period = now.Format("2006-01")
+	good := "fackavgifter-" + now.Format("2006-01-02") + ".pdf"
+	for name, write := range map[string]func(string) error{
+		good:             func(p string) error { return pdftest.WriteFile(p, r) },
+		"utan-datum.pdf": func(p string) error { return pdftest.WriteFile(p, r) },
+		"trasig-" + now.Format("060102") + ".PDF": func(p string) error { return os.WriteFile(p, []byte("inte en pdf"), 0o644) },
+		"anteckningar.txt":                        func(p string) error { return os.WriteFile(p, []byte("ingen pdf"), 0o644) },
+	} {
+		if err := write(filepath.Join(in, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := &watcher{
+		dir: in,
+		flags: watchFlags{
+			once:       true,
+			archive:    filepath.Join(dir, "arkiv"),
+			quarantine: filepath.Join(dir, "karantan"),
+			config:     cfg,
+		},
+		sizes: map[string]int64{},
+		// no date is given when asked
+		in: newPrompter(strings.NewReader(""), io.Discard),
+	}
+	if err := w.run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ls := func(d string) []string {
+		t.Helper()
+		entries, err := os.ReadDir(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, e := range entries {
+			if !e.IsDir() {
+				names = append(names, e.Name())
+			}
+		}
+		return names
+	}
+	if got := strings.Join(ls(w.flags.archive), ","); got != good+",process.log" {
+		t.Errorf("archive = %s", got)
+	}
+	if got := strings.Join(ls(w.flags.quarantine), ","); got != "trasig-"+now.Format("060102")+".PDF,utan-datum.pdf" {
+		t.Errorf("quarantine = %s", got)
+	}
+	// the union files are written next to the pdf, other files are left
+	if got := strings.Join(ls(in), ","); got != "GS-"+now.Format("0601")+".txt,IF Metall-"+now.Format("0601")+".txt,anteckningar.txt" {
+		t.Errorf("watched directory = %s", got)
+	}
+
+	data, err := os.ReadFile(filepath.Join(w.flags.archive, "process.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("process.log has %d lines, want 3:\n%s", len(lines), data)
+	}
+	want := map[string]struct{ status, dest, files, msg string }{
+		good:             {"OK", filepath.Join(w.flags.archive, good), "GS-", ""},
+		"utan-datum.pdf": {"FEL", filepath.Join(w.flags.quarantine, "utan-datum.pdf"), "", "inget datum angivet"},
+		"trasig-" + now.Format("060102") + ".PDF": {"FEL", filepath.Join(w.flags.quarantine, "trasig-"+now.Format("060102")+".PDF"), "", "trasig"},
+	}
+	for _, line := range lines {
+		cols := strings.Split(line, "\t")
+		if len(cols) != 6 {
+			t.Errorf("line has %d columns, want 6: %q", len(cols), line)
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, cols[0]); err != nil {
+			t.Errorf("time of %q: %v", line, err)
+		}
+		wl, ok := want[cols[2]]
+		if !ok {
+			t.Errorf("unexpected file in log: %q", line)
+			continue
+		}
+		if cols[1] != wl.status || cols[3] != wl.dest || !strings.Contains(cols[4], wl.files) || (wl.files == "") != (cols[4] == "") ||
+			!strings.Contains(cols[5], wl.msg) || (wl.msg == "") != (cols[5] == "") {
+			t.Errorf("log line %q, want %+v", line, wl)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kmpm/unionfees/internal"
+	"github.com/kmpm/unionfees/public/unionfees"
+)
+
+func newWizardCmd() *command {
+	var maxAge int
+	var cfgPath string
+	var debug bool
+	c := newCommand("wizard", "[katalog]", "Guidar steg för steg genom att göra om en pdf-fil")
+	c.debugPDF = func() bool { return debug }
+	c.flags.BoolVar(&debug, "debug", false, "visa felsökningsutskrifter från pdf-läsaren")
+	c.flags.IntVar(&maxAge, "maxage", internal.DefaultPeriodPolicy.MaxAge, "hur många månader bakåt en rättelse får avse")
+	c.flags.StringVar(&cfgPath, "config", "", "konfigurationsfil")
+	c.run = func(args []string) error {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		err := runWizard(newPrompter(os.Stdin, os.Stdout), dir, maxAge, cfgPath)
+		if errors.Is(err, io.EOF) {
+			fmt.Println("Avbrutet")
+			return nil
+		}
+		return err
+	}
+	return c
+}
+
+func runWizard(p *prompter, dir string, maxAge int, cfgPath string) error {
+	out := p.out
+	fmt.Fprintln(out, "Välkommen! Här skapas filer för fackavgifter från en pdf ur Visma Lön.")
+	fmt.Fprintln(out)
+
+	// 1. välj pdf
+	pdfs, err := filepath.Glob(filepath.Join(dir, "*.[pP][dD][fF]"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(pdfs)
+	if len(pdfs) == 0 {
+		fmt.Fprintf(out, "Inga pdf-filer hittades i '%s'. Lägg pdf-filen i samma mapp och försök igen.\n", dir)
+		return nil
+	}
+	fmt.Fprintln(out, "Pdf-filer i mappen:")
+	for i, f := range pdfs {
+		fmt.Fprintf(out, "  %d. %s\n", i+1, filepath.Base(f))
+	}
+	picked := []int{0}
+	if len(pdfs) > 1 {
+		for {
+			picked, err = p.choose("Vilken fil ska användas? Ange nummer", len(pdfs), false)
+			if err != nil {
+				return err
+			}
+			if len(picked) == 1 {
+				break
+			}
+			fmt.Fprintln(out, "Välj en fil")
+		}
+	}
+	filename := pdfs[picked[0]]
+
+	// 2. visa vad som hittades
+	cfg, err := unionfees.LoadConfig(cfgPath)
+	if err != nil {
+		return withCode(exitConfig, err)
+	}
+	f := convertFlags{
+		date:   time.Now().Format("060102"),
+		maxAge: maxAge,
+		outDir: dir,
+		config: cfgPath,
+	}
+	r, err := preview(&f, cfg, filename)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(r.Unions))
+	for _, u := range r.Unions {
+		names = append(names, u.Name)
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(out, "Hittade inga fackförbund i pdf-filen.")
+		return withCode(exitInput, fmt.Errorf("inga förbund i %s", filename))
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Fil:     \t%s\n", filepath.Base(filename))
+	fmt.Fprintf(out, "Företag: \t%s\n", r.CompanyName)
+	fmt.Fprintf(out, "Orgnr:   \t%s\n", r.OrgNum)
+	fmt.Fprintln(out, "Förbund:")
+	for i, u := range r.Unions {
+		fmt.Fprintf(out, "  %d. %-20s %4d medlemmar, summa %s kr\n", i+1, u.Name, u.Records, u.Sum.StringFixed(2))
+	}
+	fmt.Fprintln(out)
+
+	// 3. utbetalningsdatum
+	policy := internal.PeriodPolicy{MaxAge: maxAge}
+	var t time.Time
+	correct := false
+	for {
+		t, err = p.date("Ange utbetalningsdatum")
+		if err != nil {
+			return err
+		}
+		kind, err := policy.Check(t.Year()-2000, int(t.Month()), time.Now())
+		if err != nil {
+			fmt.Fprintf(out, "Datumet går inte att använda: %v\n", err)
+			continue
+		}
+		if kind == internal.PeriodCorrection {
+			ok, err := p.yesNo(fmt.Sprintf("Perioden %s är stängd. Vill du skapa en rättelse?", t.Format("2006-01")), false)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			correct = true
+		}
+		break
+	}
+
+	// 4. förbund
+	chosen := names
+	if len(names) > 1 {
+		idx, err := p.choose("Vilka förbund? Ange nummer, t.ex. 1,2, eller tryck Enter för alla", len(names), true)
+		if err != nil {
+			return err
+		}
+		chosen = []string{}
+		for _, i := range idx {
+			chosen = append(chosen, names[i])
+		}
+	}
+
+	// 5. bekräfta, med det som kommer att skrivas
+	f.date = t.Format("060102")
+	f.correct = correct
+	f.unions = strings.Join(chosen, ",")
+	r, err = preview(&f, cfg, filename)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Utbetalningsdatum %s, period %s", t.Format("2006-01-02"), t.Format("2006-01"))
+	if correct {
+		fmt.Fprint(out, ", rättelse")
+	}
+	fmt.Fprintln(out, "\nFiler skapas för:")
+	for _, u := range r.Unions {
+		fmt.Fprintf(out, "  %-20s förbundsnummer %2d, %4d medlemmar, summa %s kr\n", u.Name, u.Code, u.Records, u.Sum.StringFixed(2))
+	}
+	for _, d := range r.Diagnostics {
+		fmt.Fprintf(out, "Varning: %s\n", d.Message)
+	}
+	ok, err := p.yesNo("Skapa filerna?", true)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Fprintln(out, "Inga filer skapades")
+		return nil
+	}
+
+	// 6. skriv
+	_, err = runConvert(&f, []string{filename}, out)
+	if exitCode(err) == exitExists {
+		fmt.Fprintln(out, err)
+		ok, aerr := p.yesNo("Vill du skriva över befintliga filer?", false)
+		if aerr != nil {
+			return aerr
+		}
+		if !ok {
+			fmt.Fprintln(out, "Inga filer skrevs över")
+			return nil
+		}
+		f.force = true
+		_, err = runConvert(&f, []string{filename}, out)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "\nKlart! Skicka filerna till förbundet.")
+	return nil
+}
+
+// preview converts filename the way runConvert does with f, without
+// writing anything, so the totals shown are those of the files
+func preview(f *convertFlags, cfg *unionfees.Config, filename string) (*unionfees.Result, error) {
+	opts, err := f.options(cfg)
+	if err != nil {
+		return nil, err
+	}
+	in, err := readInput(filename, f.format)
+	if err != nil {
+		return nil, withCode(exitInput, fmt.Errorf("fel vid läsning av pdf: %w", err))
+	}
+	r, err := unionfees.Convert(context.Background(), in, opts)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return r, nil
+}
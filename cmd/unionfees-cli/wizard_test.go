@@ -0,0 +1,54 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kmpm/unionfees/internal/pdftest"
+)
+
+func TestRunWizard(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("UNIONFEES_HISTORY", filepath.Join(dir, "historik.jsonl"))
+	// the union is only known by the config, without it the union
+	// would get the IF Metall number
+	cfg := filepath.Join(dir, "unionfees.toml")
+	if err := os.WriteFile(cfg, []byte("[unions]\n\"Träindustriarbetarna\" = 9\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	r := pdftest.Example()
+	r.Period = now.Format("2006-01")
+	r.Unions = r.Unions[:1]
+	r.Unions[0].Name = "Träindustriarbetarna"
+	if err := pdftest.WriteFile(filepath.Join(dir, "fackavgifter.pdf"), r); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	script := now.Format("2006-01-02") + "\nj\n"
+	if err := runWizard(newPrompter(strings.NewReader(script), &out), dir, 0, cfg); err != nil {
+		t.Fatalf("runWizard() error = %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "Klart!") {
+		t.Errorf("no files were written:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "förbundsnummer  9") || strings.Contains(out.String(), "saknas i konfigurationen") {
+		t.Errorf("the preview did not use the config:\n%s", out.String())
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "Träindustriarbetarna*.txt"))
+	if len(files) != 1 {
+		t.Fatalf("files = %v, want one for the union", files)
+	}
+	_, code, err := readUnionFile(files[0], "")
+	if err != nil || code != 9 {
+		t.Errorf("union number = %d, %v, want 9 from the config", code, err)
+	}
+}
@@ -5,29 +5,108 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kmpm/unionfees/internal"
+	"github.com/kmpm/unionfees/internal/history"
+	"github.com/kmpm/unionfees/internal/output"
 	"github.com/kmpm/unionfees/internal/parser"
 	"github.com/kmpm/unionfees/internal/union"
+	"github.com/kmpm/unionfees/public/unionfees"
 )
 
-func parseToMultiZipHandler(c *gin.Context) {
-	formDate := c.PostForm("period")
-	t, err := time.Parse("2006-01-02", formDate)
+// formAccounting reads the accounting type and, if uploaded,
+// the original submission to compute a correction against.
+func formAccounting(c *gin.Context, opts *unionfees.Options) error {
+	accType, err := internal.ParseAccountingType(c.PostForm("typ"))
+	if err != nil {
+		return err
+	}
+	opts.Accounting = accType
+	fh, err := c.FormFile("original")
+	if err != nil {
+		// no original uploaded
+		return nil
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	opts.Original, opts.OriginalCode, err = unionfees.ReadOriginalEncoding(f, appConfig.Output.Encoding)
+	if err != nil {
+		return fmt.Errorf("kunde inte läsa originalfil: %w", err)
+	}
+	return nil
+}
+
+// convertFailed writes the response for an error from unionfees.Convert.
+// Errors in the upload or the form are the client's and are returned as
+// 400 with the message, a pdf over the limits as 413 and anything else
+// is logged and returned as 500.
+func convertFailed(c *gin.Context, err error) {
+	var (
+		pe *unionfees.ParseError
+		ve *unionfees.ValidationError
+		ee *unionfees.EncodingError
+		oe *unionfees.FormatOverflowError
+	)
+	switch {
+	case errors.Is(err, unionfees.ErrLimit):
+		slog.Warn("upload over limits", "error", err)
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, unionfees.ErrOptions), errors.Is(err, unionfees.ErrPeriod), errors.Is(err, unionfees.ErrInput),
+		errors.As(err, &pe), errors.As(err, &ve), errors.As(err, &ee), errors.As(err, &oe):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		slog.Error("conversion failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internt fel, se serverns logg"})
+	}
+}
+
+// convertUpload converts the uploaded file with the form values. If ok is
+// false an error response has already been written.
+func convertUpload(c *gin.Context, opts unionfees.Options) (res *unionfees.Result, ok bool) {
+	if pdfLimits.MaxSize > 0 {
+		// room for the original union file and the form fields
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 2*pdfLimits.MaxSize+1<<20)
+	}
+	if _, err := c.MultipartForm(); err != nil {
+		var me *http.MaxBytesError
+		if errors.As(err, &me) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("uppladdningen är större än %d byte", me.Limit)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t, err := time.Parse("2006-01-02", c.PostForm("period"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	opts.Config = appConfig
+	opts.PayoutDate = t
+	opts.MaxAge = periodPolicy.MaxAge
+	opts.Limits = pdfLimits
+	if pdfWorker != nil {
+		opts.ReadPDF = func(ctx context.Context, data []byte, lim unionfees.Limits) (*unionfees.Document, error) {
+			return pdfWorker.Read(ctx, data, parser.Limits(lim))
+		}
+	}
+	if err := formAccounting(c, &opts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	file, err := c.FormFile("file")
 	if err != nil {
@@ -35,109 +114,57 @@ func parseToMultiZipHandler(c *gin.Context) {
 		return
 	}
 	slog.Info(file.Filename)
-	ext := filepath.Ext(file.Filename)
-	err = ensureDir(inboundFolder)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if pdfLimits.MaxSize > 0 && file.Size > pdfLimits.MaxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("filen är för stor: %d byte, max %d", file.Size, pdfLimits.MaxSize)})
 		return
 	}
-	inb, err := os.CreateTemp(inboundFolder, fmt.Sprintf("file-*%s", ext))
+	f, err := file.Open()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-
-	defer func() {
-		// make sure inbound file is closed and removed
-		inb.Close()
-		err := os.Remove(inb.Name())
-		if err != nil {
-			slog.Error("error removing file", "filename", inb.Name(), "error", err)
-		} else {
-			slog.Debug("file removed", "filename", inb.Name())
-		}
-	}()
-
-	err = c.SaveUploadedFile(file, inb.Name())
+	defer f.Close()
+	data, err := io.ReadAll(f)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	doc, err := parser.ReadPdf(inb.Name()) // Read local pdf file
+	in := unionfees.Input{Name: file.Filename, Data: data, Format: c.PostForm("format")}
+	res, err = unionfees.Convert(c.Request.Context(), in, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		convertFailed(c, err)
 		return
 	}
-
-	matrix := doc.Pages[0].Strings()
-	kv := kvFromStrings(matrix)
-
-	companyName := kv["Namn"]
-	vatID := kv["Organisationsnr"]
-	// periodStr := kv["Period"]
-
-	cn, err := internal.Str2Person(vatID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	slog.Debug("converted document",
+		"companyName", res.CompanyName,
+		"vatID", res.OrgNum)
+	for _, d := range res.Diagnostics {
+		slog.Warn(d.Message, "union", d.Union)
 	}
+	for _, u := range res.Unions {
+		for _, l := range u.Locations {
+			slog.Info("Plats", "Förbund", u.Name, "Nr", l.S3.LocNum, "Antal", len(l.S2), "Summa", l.S3.SumAmout)
+		}
+	}
+	return res, true
+}
 
-	slog.Debug("parsed document",
-		"companyName", companyName,
-		"vatID", vatID)
-
-	now := time.Now()
-
-	flagPeriod := int(t.Month())
-	flagYear := t.Year() - 2000
-	if flagYear < 0 || flagYear > 99 || flagYear < (now.Year()-2000) {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Felaktigt år: %d", flagYear)})
+func parseToMultiZipHandler(c *gin.Context) {
+	res, ok := convertUpload(c, unionfees.Options{})
+	if !ok {
 		return
 	}
 
 	zbuff := new(bytes.Buffer)
-	zf, err := NewZipFile(zbuff)
+	zf, err := output.NewZipFile(zbuff)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-
-	for k, v := range doc.GetTables() {
-		fmt.Println(k)
-
-		listS2, err := internal.ConvertS2Data(1, v)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		locs := internal.BuildLocations(
-			internal.CompanyArgs{
-				CompanyNum:      cn,
-				CompanyName:     companyName,
-				Period:          flagPeriod,
-				Year:            flagYear,
-				TransactionDate: t,
-			},
-			listS2,
-		)
-		for _, l := range locs {
-			// fmt.Printf("Plats %d, Antal: %d, Summa: %s\n", l.S3.LocNum, len(l.S2), l.S3.SumAmout)
-			slog.Info("Plats", "Nr", l.S3.LocNum, "Antal", len(l.S2), "Summa", l.S3.SumAmout)
-		}
-
-		filename := fmt.Sprintf("%s-%02d%02d.txt", k, flagYear, flagPeriod)
-
-		buff := new(bytes.Buffer)
-
-		err = union.WriteTable(buff, locs, union.CodeIFMetall)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		slog.Debug("file created", "filename", filename)
-		_, err = zf.AddFile(filename, bufio.NewReader(buff))
+	for _, file := range res.Files() {
+		slog.Debug("file created", "filename", file.Name)
+		_, err = zf.AddFile(file.Name, bytes.NewReader(file.Data))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -146,12 +173,16 @@ func parseToMultiZipHandler(c *gin.Context) {
 
 	zf.Close()
 	extraHeaders := map[string]string{
-		"Content-Disposition": fmt.Sprintf("attachment; filename=%s-%02d%02d.zip", companyName, flagYear, flagPeriod),
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%s-%02d%02d.zip", res.CompanyName, res.Year, res.Period),
 	}
 	c.DataFromReader(http.StatusOK, int64(zbuff.Len()), "application/zip", zbuff, extraHeaders)
 }
 
-func parseToFirstTxtHandler(c *gin.Context) {
+// uploadFile converts the uploaded file and returns the file for the
+// selected union. A union that is not in the config gets the IF Metall
+// number like in Convert, so it is used if no configured union has the
+// number. If ok is false an error response has already been written.
+func uploadFile(c *gin.Context) (res *unionfees.Result, file unionfees.File, ok bool) {
 	formUnionNo := c.PostForm("union")
 	if formUnionNo == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "unioNo is required"})
@@ -163,119 +194,121 @@ func parseToFirstTxtHandler(c *gin.Context) {
 		return
 	}
 
-	formDate := c.PostForm("period")
-	t, err := time.Parse("2006-01-02", formDate)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	res, ok = convertUpload(c, unionfees.Options{})
+	if !ok {
 		return
 	}
-
-	file, err := c.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	slog.Info(file.Filename)
-	ext := filepath.Ext(file.Filename)
-	err = ensureDir(inboundFolder)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	for _, configured := range []bool{true, false} {
+		for _, u := range res.Unions {
+			if u.Configured == configured && u.Code == int(unionNo) && len(u.Files) > 0 {
+				return res, u.Files[0], true
+			}
+		}
 	}
-	inb, err := os.CreateTemp(inboundFolder, fmt.Sprintf("file-*%s", ext))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("%s finns inte i filen", unionNo)})
+	return res, file, false
+}
+
+// historyEntry records that file was created from res
+func historyEntry(res *unionfees.Result, file unionfees.File) history.Entry {
+	return history.Entry{
+		Hash:       res.Hash,
+		File:       res.File,
+		Company:    res.CompanyName,
+		OrgNum:     res.OrgNum,
+		Year:       res.Year,
+		Period:     res.Period,
+		Accounting: res.Accounting,
+		Unions:     []int{file.Code},
+		Files:      []string{file.Name},
 	}
+}
 
-	defer func() {
-		// make sure inbound file is closed and removed
-		inb.Close()
-		err := os.Remove(inb.Name())
-		if err != nil {
-			slog.Error("error removing file", "filename", inb.Name(), "error", err)
-		} else {
-			slog.Info("file removed", "filename", inb.Name())
+// diagnostics returns the messages of res for the union and for the
+// whole file
+func diagnostics(res *unionfees.Result, unionName string) []string {
+	msgs := []string{}
+	for _, d := range res.Diagnostics {
+		if d.Union == "" || d.Union == unionName {
+			msgs = append(msgs, d.Message)
 		}
-	}()
-
-	err = c.SaveUploadedFile(file, inb.Name())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
 	}
+	return msgs
+}
 
-	doc, err := parser.ReadPdf(inb.Name()) // Read local pdf file
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// duplicates describes the earlier conversions that e duplicates
+func duplicates(e history.Entry) []string {
+	if convHistory == nil {
+		return nil
 	}
-
-	matrix := doc.Pages[0].Strings()
-	kv := kvFromStrings(matrix)
-
-	companyName := kv["Namn"]
-	vatID := kv["Organisationsnr"]
-	// periodStr := kv["Period"]
-
-	cn, err := internal.Str2Person(vatID)
+	matches, err := convHistory.Find(e)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		slog.Error("reading history", "error", err)
 	}
+	return describe(matches)
+}
 
-	slog.Info("parsed document",
-		"companyName", companyName,
-		"vatID", vatID)
+func describe(matches []history.Match) []string {
+	msgs := []string{}
+	for _, m := range matches {
+		msgs = append(msgs, m.String())
+	}
+	return msgs
+}
 
-	now := time.Now()
-	flagPeriod := int(t.Month())
-	flagYear := t.Year() - 2000
-	if flagYear < 0 || flagYear > 99 || flagYear < (now.Year()-2000) {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Felaktigt år: %d", flagYear)})
+// parseToFirstTxtHandler returns the file for the selected union. A file
+// that has been created before is refused with 409 unless the form
+// field dubblett is set.
+func parseToFirstTxtHandler(c *gin.Context) {
+	res, file, ok := uploadFile(c)
+	if !ok {
 		return
 	}
-
-	for k, v := range doc.GetTables() {
-
-		if !strings.Contains(k, "Metall") {
-			continue
-		}
-
-		listS2, err := internal.ConvertS2Data(1, v)
+	if convHistory != nil {
+		// checked and recorded as one step, so that two uploads of the
+		// same report at once can not both pass
+		var dups []string
+		err := convHistory.Record(historyEntry(res, file), func(matches []history.Match, err error) bool {
+			if err != nil {
+				slog.Error("reading history", "error", err)
+			}
+			dups = describe(matches)
+			return len(dups) == 0 || c.PostForm("dubblett") != ""
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		locs := internal.BuildLocations(
-			internal.CompanyArgs{
-				CompanyNum:      cn,
-				CompanyName:     companyName,
-				Period:          flagPeriod,
-				Year:            flagYear,
-				TransactionDate: t,
-			},
-			listS2,
-		)
-		for _, l := range locs {
-			slog.Info("Plats", "Nr", l.S3.LocNum, "Antal", len(l.S2), "Summa", l.S3.SumAmout)
+			slog.Error("writing history", "error", err)
 		}
-
-		filename := fmt.Sprintf("%s-%02d%02d.txt", k, flagYear, flagPeriod)
-
-		buff := new(bytes.Buffer)
-		err = union.WriteTable(buff, locs, unionNo)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+		if len(dups) > 0 {
+			slog.Warn("duplicate upload", "file", res.File, "sha256", res.Hash, "duplicates", dups)
+			if c.PostForm("dubblett") == "" {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":      strings.Join(dups, "; ") + ", kryssa i att filen ska skapas ändå för att få den",
+					"duplicates": dups,
+				})
+				return
+			}
 		}
+	}
+	extraHeaders := map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%s", file.Name),
+	}
+	c.DataFromReader(http.StatusOK, int64(len(file.Data)), "text/plain", bytes.NewReader(file.Data), extraHeaders)
+}
 
-		extraHeaders := map[string]string{
-			"Content-Disposition": fmt.Sprintf("attachment; filename=%s", filename),
-		}
-		c.DataFromReader(http.StatusOK, int64(buff.Len()), "text/plain", buff, extraHeaders)
+// explainHandler shows every line of the file that /upload/ would create
+// together with where in the pdf it came from
+func explainHandler(c *gin.Context) {
+	res, file, ok := uploadFile(c)
+	if !ok {
 		return
 	}
-
+	unionNo := union.UnionCode(file.Code)
+	c.HTML(http.StatusOK, "explain.tmpl", gin.H{
+		"title":    "Förklaring av " + file.Name,
+		"version":  appVersion,
+		"union":    unionNo.String(),
+		"filename": file.Name,
+		"lines":    union.Lines(file.Locations, unionNo),
+		"warnings": append(diagnostics(res, file.Union), duplicates(historyEntry(res, file))...),
+	})
 }
@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kmpm/unionfees/internal/history"
+	"github.com/kmpm/unionfees/internal/pdftest"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// upload posts a report for the current period to /upload/
+func upload(t *testing.T, r pdftest.Report, unionNo string) *httptest.ResponseRecorder {
+	t.Helper()
+	now := time.Now()
+	r.Period = now.Format("2006-01")
+	data, err := pdftest.Bytes(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("union", unionNo)
+	mw.WriteField("period", now.Format("2006-01-02"))
+	fw, err := mw.CreateFormFile("file", "fackavgifter.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.POST("/upload/", parseToFirstTxtHandler)
+	req := httptest.NewRequest(http.MethodPost, "/upload/", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestUploadUnmappedUnion(t *testing.T) {
+	r := pdftest.Example()
+	r.Unions = r.Unions[:1]
+	r.Unions[0].Name = "Träindustriarbetarna"
+
+	w := upload(t, r, "38")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Disposition"); !strings.Contains(got, "Träindustriarbetarna") {
+		t.Errorf("Content-Disposition = %q, want the file of the union", got)
+	}
+
+	if w := upload(t, r, "43"); w.Code != http.StatusNotFound {
+		t.Errorf("status for GS = %d, want 404", w.Code)
+	}
+}
+
+func TestUploadConcurrentDuplicate(t *testing.T) {
+	convHistory = history.Open(filepath.Join(t.TempDir(), "historik.jsonl"))
+	t.Cleanup(func() { convHistory = nil })
+
+	var wg sync.WaitGroup
+	codes := make([]int, 8)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = upload(t, pdftest.Example(), "38").Code
+		}()
+	}
+	wg.Wait()
+	served := 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusOK:
+			served++
+		case http.StatusConflict:
+		default:
+			t.Errorf("status = %d", code)
+		}
+	}
+	if served != 1 {
+		t.Errorf("status = %v, want the file served once", codes)
+	}
+}
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"flag"
 	"fmt"
@@ -20,13 +21,25 @@ import (
 	"github.com/gin-contrib/sessions/cookie"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kmpm/unionfees/internal"
+	"github.com/kmpm/unionfees/internal/config"
+	"github.com/kmpm/unionfees/internal/history"
+	"github.com/kmpm/unionfees/internal/worker"
+	"github.com/kmpm/unionfees/public/unionfees"
 )
 
 var programLevel = new(slog.LevelVar)
 var appVersion = "v0.0.0-dev"
 var defaultSessionKey = "REPLACE-ME-*H)dC/),{%;6&zrr(almasdr3SFAE2"
+var periodPolicy = internal.DefaultPeriodPolicy
+var appConfig = config.Default()
+var pdfLimits = unionfees.DefaultLimits
 
-const inboundFolder = "./var/inbound/"
+// pdfWorker parses uploaded pdfs in a child process if set
+var pdfWorker *worker.Process
+
+// convHistory records created files to warn about duplicates, nil if disabled
+var convHistory *history.Store
 
 //go:embed assets/* templates/*
 var f embed.FS
@@ -34,16 +47,33 @@ var f embed.FS
 func main() {
 	var err error
 	var address string
-	var verbosity, mode, sessionKey, socketPath string
+	var verbosity, mode, sessionKey, socketPath, configPath string
 	var fd int
 	flag.StringVar(&address, "address", "127.0.0.1:8080", "port to listen on")
 	flag.StringVar(&verbosity, "verbosity", "info", "verbosity level")
 	flag.StringVar(&mode, "mode", "release", "mode to run in")
 	flag.StringVar(&sessionKey, "session", defaultSessionKey, "session key (SESSION_KEY)")
 	flag.StringVar(&socketPath, "socket", "", "unix socket path")
+	flag.StringVar(&configPath, "config", "", "config file (UNIONFEES_CONFIG or "+config.FileName+")")
+	flag.IntVar(&periodPolicy.MaxAge, "maxage", periodPolicy.MaxAge, "max number of months back a correction may be for")
+	flag.Int64Var(&pdfLimits.MaxSize, "maxsize", pdfLimits.MaxSize, "max size in bytes of an uploaded file, 0 for no limit")
+	flag.IntVar(&pdfLimits.MaxPages, "maxpages", pdfLimits.MaxPages, "max number of pages in an uploaded pdf, 0 for no limit")
+	flag.DurationVar(&pdfLimits.Timeout, "timeout", pdfLimits.Timeout, "max time to parse an uploaded pdf, 0 for no limit")
+	flag.Int64Var(&pdfLimits.MaxStream, "maxstream", pdfLimits.MaxStream, "max decoded bytes of content on a pdf page, 0 for no limit")
+	flag.IntVar(&pdfLimits.MaxTexts, "maxtexts", pdfLimits.MaxTexts, "max number of text fragments on a pdf page, 0 for no limit")
+	var isolate, workerMode bool
+	var workerMem int64
+	flag.BoolVar(&isolate, "isolate", false, "parse uploaded pdfs in a child process with cpu and memory limits")
+	flag.Int64Var(&workerMem, "workermem", 512<<20, "max memory in bytes the child process may use for parsing with -isolate")
+	flag.BoolVar(&workerMode, "worker", false, "run as the child process started by -isolate, not for direct use")
 
 	flag.Parse()
 
+	if workerMode {
+		err := worker.Serve(context.Background(), os.Stdin, os.Stdout)
+		os.Exit(worker.ExitCode(err))
+	}
+
 	if !isFlagPassed("session") {
 		e := os.Getenv("SESSION_KEY")
 		if e != "" {
@@ -53,6 +83,34 @@ func main() {
 
 	setupLog(verbosity)
 
+	appConfig, err = config.Load(configPath)
+	if err == nil {
+		err = appConfig.Check()
+	}
+	if err == nil {
+		_, err = appConfig.Adapters()
+	}
+	if err != nil {
+		slog.Error("error loading config", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("config loaded", "path", appConfig.Path)
+	if p := appConfig.HistoryPath(); p != "" {
+		convHistory = history.Open(p)
+		slog.Info("recording converted files", "history", p)
+	}
+
+	if isolate {
+		pdfWorker = &worker.Process{
+			Args:    []string{"-worker"},
+			Rlimits: worker.Rlimits{CPU: pdfLimits.Timeout, Memory: workerMem},
+		}
+		if !worker.RlimitsSupported {
+			slog.Warn("cpu and memory limits are not supported on this system, pdfs are parsed in a child process without them")
+		}
+		slog.Info("parsing pdfs in child processes", "memory", workerMem, "cpu", pdfLimits.Timeout)
+	}
+
 	slog.Info("starting unionfees-server", "version", appVersion, "mode", mode, "verbosity", verbosity)
 	fd, err = getSystemdSocketHandle()
 	if err != nil {
@@ -106,6 +164,7 @@ func main() {
 	})
 
 	r.POST("/upload/", parseToFirstTxtHandler)
+	r.POST("/explain/", explainHandler)
 	r.GET("/favicon.ico", func(c *gin.Context) {
 		c.Redirect(http.StatusMovedPermanently, "/public/assets/favicon.ico")
 	})
@@ -2,7 +2,10 @@
 #
 # SPDX-License-Identifier: MIT
 
-# Check for unionfees-cli.exe
+# Behandlar alla pdf-filer i mappen en gång med 'unionfees-cli watch'.
+# Utbetalningsdatum tas från filnamnet (ÅÅMMDD eller ÅÅÅÅ-MM-DD) eller frågas efter.
+# Behandlade filer flyttas till .\arkiv och filer som inte gick att behandla till .\karantan,
+# se .\arkiv\process.log.
 $exepath = "$PSScriptRoot\unionfees-cli.exe"
 
 if ($null -eq (Get-Command "$exepath" -ErrorAction SilentlyContinue)) 
@@ -11,44 +14,5 @@ if ($null -eq (Get-Command "$exepath" -ErrorAction SilentlyContinue))
     Exit 1
 }
 
-$count = (Get-ChildItem -Filter *.pdf | Measure-Object).Count
-
-if ($count -gt 1)
-{
-    Write-Host 'Mer än 1 pdf-dokument i mappen!' -fore red
-    Exit 1
-}
-
-if ($count -eq 0)
-{
-    Write-Host 'Inga pdf-dokument hittades. Inget att göra'
-    Exit 0
-}
-
-while(1){
-	Try{
-		$d = [datetime](read-host 'Ange utbetalningsdatum ÅÅÅÅ-MM-DD')
-		break
-    }
-	Catch{
-		Write-Host 'Inte giltigt datum' -fore red
-    }
-}
-
-$ds = $d.ToString('yyMMdd')
-
-Write-Host "d: '$ds' $PSScriptRoot"
-
-New-Item -ItemType Directory -Force -Path ".\arkiv"
-
-Get-ChildItem -Filter *.pdf | 
-Foreach-Object {
-    Write-Host "Bearbetar $($_.FullName)"
-    & $exepath -d $ds $_.FullName
-    if ($LASTEXITCODE -eq 0){
-        Write-Host "Flyttar $($_) till arkiv."
-        Move-Item $_ -Destination ".\arkiv"
-    }
-}
-
-Write-Host "Klar"
\ No newline at end of file
+& $exepath watch -once .
+Exit $LASTEXITCODE
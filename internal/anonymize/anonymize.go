@@ -0,0 +1,234 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+// Package anonymize replaces personal and company data in a parsed report
+// so that it can be shared as a test fixture.
+package anonymize
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kmpm/unionfees/internal/parser"
+	"github.com/ledongthuc/pdf"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// personnummer or organisationsnummer, with or without century and dash
+	reIDNum  = regexp.MustCompile(`^(\d{2})?(\d{6})(-?)(\d{4})$`)
+	reAmount = regexp.MustCompile(`^(-?)(\d[\d ]*),(\d{2})$`)
+)
+
+var firstNames = []string{
+	"Alma", "Astrid", "Ebba", "Elsa", "Freja", "Ingrid", "Karin", "Maja", "Saga", "Wilma",
+	"Arvid", "Axel", "Björn", "Erik", "Gustav", "Hugo", "Johan", "Lars", "Nils", "Olof",
+}
+
+var lastNames = []string{
+	"Andersson", "Berg", "Dahl", "Ek", "Forsberg", "Holm", "Johansson", "Lind", "Lundqvist",
+	"Nilsson", "Nyström", "Sandberg", "Sjöberg", "Strand", "Wallin", "Åberg", "Öberg",
+}
+
+// Stats counts what was replaced
+type Stats struct {
+	Names   int
+	IDNums  int
+	Amounts int
+	Company int
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d namn, %d person-/organisationsnummer, %d belopp och %d företagsuppgifter ersatta",
+		s.Names, s.IDNums, s.Amounts, s.Company)
+}
+
+// Anonymizer replaces values consistently, the same value is always
+// replaced with the same fake value.
+type Anonymizer struct {
+	rng     *rand.Rand
+	factor  decimal.Decimal
+	seen    map[string]string
+	company map[string]string
+	names   map[string]bool
+	Stats   Stats
+}
+
+// New creates an Anonymizer, the same seed gives the same fake values
+func New(seed uint64) *Anonymizer {
+	rng := rand.New(rand.NewPCG(seed, seed>>1|1))
+	return &Anonymizer{
+		rng: rng,
+		// amounts are scaled by the same factor so they keep their proportions
+		factor:  decimal.NewFromInt(int64(80 + rng.IntN(41))).Div(decimal.NewFromInt(100)),
+		seen:    map[string]string{},
+		company: map[string]string{},
+		names:   map[string]bool{},
+	}
+}
+
+// Document returns a copy of doc where member names, personnummer,
+// organisationsnummer, the company name and amounts are replaced.
+// Positions, fonts and sizes are kept so the copy parses like the original.
+// Amounts are scaled, sums can therefore differ by a few öre.
+func (a *Anonymizer) Document(doc *parser.Document, companyName, orgNum string) *parser.Document {
+	if companyName != "" {
+		a.company[companyName] = "Exempelföretaget AB"
+	}
+	if orgNum != "" {
+		a.company[orgNum] = a.idNum(orgNum, true)
+	}
+	for _, rows := range doc.Tables() {
+		for _, r := range rows {
+			if len(r.Cells) > 1 {
+				a.names[r.Cells[1]] = true
+			}
+		}
+	}
+
+	out := &parser.Document{}
+	for _, p := range doc.Pages {
+		texts := []pdf.Text{}
+		for _, run := range parser.Runs(p.Texts) {
+			texts = append(texts, a.run(run)...)
+		}
+		out.AddPage(parser.NewPage(p.Width, p.Height, texts))
+	}
+	return out
+}
+
+// run replaces the text of a run of fragments. A changed run is written
+// as one fragment per character spread over the original width.
+func (a *Anonymizer) run(run []pdf.Text) []pdf.Text {
+	var b strings.Builder
+	for _, t := range run {
+		b.WriteString(t.S)
+	}
+	s := b.String()
+	n := a.replace(s)
+	if n == s {
+		return run
+	}
+
+	first, last := run[0], run[len(run)-1]
+	width := last.X + last.W - first.X
+	w := width / float64(max(1, utf8.RuneCountInString(n)))
+	texts := make([]pdf.Text, 0, len(n))
+	x := first.X
+	for _, r := range n {
+		texts = append(texts, pdf.Text{Font: first.Font, FontSize: first.FontSize, X: x, Y: first.Y, W: w, S: string(r)})
+		x += w
+	}
+	return texts
+}
+
+// replace returns the fake value for s, or s if nothing in it is sensitive
+func (a *Anonymizer) replace(s string) string {
+	if v, ok := a.seen[s]; ok {
+		return v
+	}
+	v := s
+	for real, fake := range a.company {
+		if strings.Contains(v, real) {
+			v = strings.ReplaceAll(v, real, fake)
+			a.Stats.Company++
+		}
+	}
+	switch {
+	case v != s:
+	case a.names[s]:
+		v = a.name(s)
+		a.Stats.Names++
+	case reIDNum.MatchString(strings.TrimSpace(s)):
+		v = a.idNum(strings.TrimSpace(s), false)
+		a.Stats.IDNums++
+	case reAmount.MatchString(strings.TrimSpace(s)):
+		v = a.amount(strings.TrimSpace(s))
+		a.Stats.Amounts++
+	}
+	a.seen[s] = v
+	return v
+}
+
+// name makes a fake name at least as long as the original, keeping
+// a nickname in parenthesis
+func (a *Anonymizer) name(orig string) string {
+	first := firstNames[a.rng.IntN(len(firstNames))]
+	last := lastNames[a.rng.IntN(len(lastNames))]
+	if strings.Contains(orig, "(") {
+		first += " (" + firstNames[a.rng.IntN(len(firstNames))][:3] + ")"
+	}
+	for utf8.RuneCountInString(first+" "+last) < utf8.RuneCountInString(orig) {
+		first += " " + firstNames[a.rng.IntN(len(firstNames))]
+	}
+	return first + " " + last
+}
+
+// idNum makes a fake personnummer, or organisationsnummer if org is set,
+// in the same format as orig and with a valid check digit
+func (a *Anonymizer) idNum(orig string, org bool) string {
+	m := reIDNum.FindStringSubmatch(orig)
+	if m == nil {
+		return orig
+	}
+	var nine string
+	if org {
+		nine = fmt.Sprintf("55%04d%03d", a.rng.IntN(10000), a.rng.IntN(1000))
+	} else {
+		nine = fmt.Sprintf("%02d%02d%02d%03d", 50+a.rng.IntN(50), 1+a.rng.IntN(12), 1+a.rng.IntN(28), a.rng.IntN(1000))
+	}
+	ten := nine + string(rune('0'+CheckDigit(nine)))
+	century := ""
+	if m[1] != "" {
+		century = "19"
+		if org {
+			century = "16"
+		}
+	}
+	return century + ten[:6] + m[3] + ten[6:]
+}
+
+// amount scales an amount written like 1 234,50
+func (a *Anonymizer) amount(orig string) string {
+	m := reAmount.FindStringSubmatch(orig)
+	d, err := decimal.NewFromString(strings.ReplaceAll(m[2], " ", "") + "." + m[3])
+	if err != nil {
+		return orig
+	}
+	v := strings.Replace(d.Mul(a.factor).StringFixed(2), ".", ",", 1)
+	if strings.Contains(m[2], " ") {
+		v = groupThousands(v)
+	}
+	return m[1] + v
+}
+
+// groupThousands writes 1234,50 as 1 234,50
+func groupThousands(v string) string {
+	i := strings.Index(v, ",")
+	intPart, rest := v[:i], v[i:]
+	for j := len(intPart) - 3; j > 0; j -= 3 {
+		intPart = intPart[:j] + " " + intPart[j:]
+	}
+	return intPart + rest
+}
+
+// CheckDigit is the Luhn check digit for the first nine digits of a
+// personnummer or organisationsnummer
+func CheckDigit(nine string) int {
+	sum := 0
+	for i, r := range nine {
+		d := int(r - '0')
+		if i%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
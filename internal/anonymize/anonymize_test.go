@@ -0,0 +1,98 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package anonymize
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/kmpm/unionfees/internal/parser"
+)
+
+func TestCheckDigit(t *testing.T) {
+	tests := []struct {
+		nine string
+		want int
+	}{
+		{"811218987", 6}, // Skatteverkets exempel 811218-9876
+		{"556036079", 3}, // organisationsnummer 556036-0793
+	}
+	for _, tt := range tests {
+		if got := CheckDigit(tt.nine); got != tt.want {
+			t.Errorf("CheckDigit(%s) = %d, want %d", tt.nine, got, tt.want)
+		}
+	}
+}
+
+func TestDocument(t *testing.T) {
+	doc, err := parser.LoadFile("../../testdata/fixtures/fackavgifter-enkel.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	anon := New(1).Document(doc, "Exempel AB", "556677-8899")
+
+	var got bytes.Buffer
+	if err := anon.Save(&got); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"Exempel AB", "556677-8899", "Anna Andersson", "850101-1234", "19790202-2345", "412,00"} {
+		if strings.Contains(textOf(anon), secret) {
+			t.Errorf("%q is still in the anonymized document", secret)
+		}
+	}
+
+	want, gotTables := doc.Tables(), anon.Tables()
+	if len(gotTables) != len(want) {
+		t.Fatalf("got %d tables, want %d", len(gotTables), len(want))
+	}
+	for k, rows := range want {
+		if len(gotTables[k]) != len(rows) {
+			t.Fatalf("%s: got %d rows, want %d", k, len(gotTables[k]), len(rows))
+		}
+		for i, r := range rows {
+			g := gotTables[k][i]
+			if g.Page != r.Page || g.Y != r.Y || len(g.Cells) != len(r.Cells) {
+				t.Errorf("%s row %d = %+v, want same position and cells as %+v", k, i, g, r)
+				continue
+			}
+			if g.Cells[0] != r.Cells[0] {
+				t.Errorf("%s row %d: employee number changed", k, i)
+			}
+			pnr := strings.ReplaceAll(g.Cells[2], "-", "")
+			pnr = pnr[len(pnr)-10:]
+			if CheckDigit(pnr[:9]) != int(pnr[9]-'0') {
+				t.Errorf("%s row %d: %s has an invalid check digit", k, i, g.Cells[2])
+			}
+			if len(g.Cells[2]) != len(r.Cells[2]) {
+				t.Errorf("%s row %d: %s is not in the same format as %s", k, i, g.Cells[2], r.Cells[2])
+			}
+			if strings.Contains(r.Cells[1], "(") != strings.Contains(g.Cells[1], "(") {
+				t.Errorf("%s row %d: nickname not kept in %q", k, i, g.Cells[1])
+			}
+		}
+	}
+
+	// same seed, same result
+	again := New(1).Document(doc, "Exempel AB", "556677-8899")
+	var b bytes.Buffer
+	again.Save(&b)
+	if b.String() != got.String() {
+		t.Error("same seed gave a different document")
+	}
+}
+
+func textOf(doc *parser.Document) string {
+	var b strings.Builder
+	for _, p := range doc.Pages {
+		for _, run := range parser.Runs(p.Texts) {
+			for _, t := range run {
+				b.WriteString(t.S)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,387 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+// Package config loads company defaults shared by the cli and the server.
+package config
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/kmpm/unionfees/internal"
+	"github.com/kmpm/unionfees/internal/input"
+	"github.com/kmpm/unionfees/internal/parser"
+	"github.com/kmpm/unionfees/internal/union"
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// FileName is the name looked for in the working directory and the user config directory
+const FileName = "unionfees.toml"
+
+// EnvPrefix is the prefix of environment variables overriding the file
+const EnvPrefix = "UNIONFEES_"
+
+type Config struct {
+	Company Company `toml:"company"`
+	// Unions maps union names in the report to union numbers.
+	// A name matches if it contains the words of the key, ignoring case,
+	// the longest key wins if several do.
+	Unions map[string]int `toml:"unions"`
+	// DefaultLocation is used for members not in Locations
+	DefaultLocation int `toml:"default_location"`
+	// Layouts is a directory with report templates in addition to the
+	// built in ones, relative to the config file
+	Layouts string `toml:"layouts"`
+	// History is the file where converted files are recorded to find
+	// duplicates, relative to the config file. Empty for the default
+	// in the user config directory, "-" for no history.
+	History string `toml:"history"`
+	// Locations maps personnummer to location (arbetsställe) number
+	Locations map[string]int `toml:"locations"`
+	// PayCodes are applied in order, the first matching rule wins
+	PayCodes []PayCodeRule `toml:"paycode"`
+	Output   Output        `toml:"output"`
+	// CSV maps the columns of csv files from other payroll systems,
+	// nil if not configured
+	CSV *input.CSVMapping `toml:"csv"`
+
+	// Path is where the config was loaded from, empty for defaults
+	Path string `toml:"-"`
+}
+
+type Company struct {
+	Name   string `toml:"name"`
+	OrgNum string `toml:"orgnum"`
+}
+
+// PayCodeRule sets the pay code for matching members.
+// A rule without conditions matches everyone.
+type PayCodeRule struct {
+	Person     string `toml:"person"`
+	ZeroAmount bool   `toml:"zero_amount"`
+	Code       int    `toml:"code"`
+}
+
+type Output struct {
+	Dir      string `toml:"dir"`
+	Template string `toml:"template"`
+	Encoding string `toml:"encoding"`
+}
+
+// Default returns the built in configuration
+func Default() *Config {
+	return &Config{
+		Unions: map[string]int{
+			"Metall": int(union.CodeIFMetall),
+			"GS":     int(union.CodeGSUnion),
+		},
+		DefaultLocation: 1,
+		Locations:       map[string]int{},
+		Output: Output{
+			Encoding: union.DefaultEncoding,
+		},
+	}
+}
+
+// Find returns the path of the config file to use, or "" if there is none.
+// UNIONFEES_CONFIG is used if set, otherwise the working directory
+// and then the user config directory are searched.
+func Find() string {
+	if p := os.Getenv(EnvPrefix + "CONFIG"); p != "" {
+		return p
+	}
+	candidates := []string{FileName}
+	if dir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, filepath.Join(dir, "unionfees", FileName))
+	}
+	for _, p := range candidates {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// Load reads the config at path on top of the defaults and applies environment
+// overrides. An empty path means Find, and defaults if nothing is found.
+func Load(path string) (*Config, error) {
+	if path == "" {
+		path = Find()
+	}
+	cfg := Default()
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return cfg, err
+		}
+		defer f.Close()
+		if err := cfg.decode(f); err != nil {
+			return cfg, fmt.Errorf("%s: %w", path, err)
+		}
+		cfg.Path = path
+	}
+	cfg.applyEnv()
+	return cfg, nil
+}
+
+func (c *Config) decode(r io.Reader) error {
+	d := toml.NewDecoder(r)
+	d.DisallowUnknownFields()
+	return d.Decode(c)
+}
+
+// applyEnv overrides values with UNIONFEES_* environment variables
+func (c *Config) applyEnv() {
+	env := map[string]*string{
+		"COMPANY_NAME":    &c.Company.Name,
+		"ORGNUM":          &c.Company.OrgNum,
+		"OUTPUT_DIR":      &c.Output.Dir,
+		"OUTPUT_TEMPLATE": &c.Output.Template,
+		"ENCODING":        &c.Output.Encoding,
+		"HISTORY":         &c.History,
+	}
+	for k, v := range env {
+		if e, ok := os.LookupEnv(EnvPrefix + k); ok {
+			*v = e
+		}
+	}
+}
+
+// Check validates the config and returns all problems found
+func (c *Config) Check() error {
+	var errs []error
+	if c.Company.OrgNum != "" {
+		if _, err := internal.Str2Person(c.Company.OrgNum); err != nil {
+			errs = append(errs, fmt.Errorf("company.orgnum: %w", err))
+		}
+	}
+	if c.Company.Name != "" && len(c.Company.Name) < 3 {
+		errs = append(errs, fmt.Errorf("company.name måste vara längre än 3 tecken"))
+	}
+	for name, code := range c.Unions {
+		if code < 1 || code > 99 {
+			errs = append(errs, fmt.Errorf("unions.%s: förbundsnummer %d utanför 1-99", name, code))
+		}
+	}
+	if c.DefaultLocation < 1 || c.DefaultLocation > 9999 {
+		errs = append(errs, fmt.Errorf("default_location: %d utanför 1-9999", c.DefaultLocation))
+	}
+	for p, loc := range c.Locations {
+		if _, err := internal.Str2Person(p); err != nil {
+			errs = append(errs, fmt.Errorf("locations.%s: %w", p, err))
+		}
+		if loc < 1 || loc > 9999 {
+			errs = append(errs, fmt.Errorf("locations.%s: %d utanför 1-9999", p, loc))
+		}
+	}
+	for i, r := range c.PayCodes {
+		switch spec.PayCode(r.Code) {
+		case spec.PayCodeAmountPayed, spec.PayCodeTimeOff, spec.PayCodeOther,
+			spec.PayCodeEndEmployment, spec.PayCodeMissingPermission:
+		default:
+			errs = append(errs, fmt.Errorf("paycode[%d]: okänd betalkod %d", i, r.Code))
+		}
+		if r.Person != "" {
+			if _, err := internal.Str2Person(r.Person); err != nil {
+				errs = append(errs, fmt.Errorf("paycode[%d].person: %w", i, err))
+			}
+		}
+	}
+	if _, err := union.Encoding(c.Output.Encoding); err != nil {
+		errs = append(errs, fmt.Errorf("output.encoding: %w", err))
+	}
+	if _, err := c.LoadLayouts(); err != nil {
+		errs = append(errs, fmt.Errorf("layouts: %w", err))
+	}
+	if c.CSV != nil {
+		if err := c.CSV.Check(); err != nil {
+			errs = append(errs, fmt.Errorf("csv: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LoadLayouts reads the report templates in the Layouts directory
+func (c *Config) LoadLayouts() ([]*parser.Template, error) {
+	if c.Layouts == "" {
+		return nil, nil
+	}
+	dir := c.Layouts
+	if !filepath.IsAbs(dir) && c.Path != "" {
+		dir = filepath.Join(filepath.Dir(c.Path), dir)
+	}
+	return parser.LoadTemplates(dir)
+}
+
+// HistoryFile is the default name of the history file
+const HistoryFile = "historik.jsonl"
+
+// HistoryPath returns the history file to use, "" if there is none
+func (c *Config) HistoryPath() string {
+	switch {
+	case c.History == "-":
+		return ""
+	case c.History == "":
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return ""
+		}
+		return filepath.Join(dir, "unionfees", HistoryFile)
+	case !filepath.IsAbs(c.History) && c.Path != "":
+		return filepath.Join(filepath.Dir(c.Path), c.History)
+	}
+	return c.History
+}
+
+// Adapters returns the input adapters with the configured layouts and
+// csv mapping
+func (c *Config) Adapters() ([]input.Adapter, error) {
+	layouts, err := c.LoadLayouts()
+	if err != nil {
+		return nil, err
+	}
+	return input.Adapters(layouts, c.CSV), nil
+}
+
+// UnionCode returns the union number for a union name in the report.
+// A key equal to the name is used first, then the longest key whose
+// words start words of the name, in order, so "IF Metall" and
+// "Metallförbundet" match "Metall" but "Försäkringsfacket" does not
+// match "GS".
+func (c *Config) UnionCode(name string) (union.UnionCode, bool) {
+	for k, code := range c.Unions {
+		if strings.EqualFold(k, name) {
+			return union.UnionCode(code), true
+		}
+	}
+	keys := slices.SortedFunc(maps.Keys(c.Unions), func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(b), len(a)), strings.Compare(a, b))
+	})
+	nameWords := words(name)
+	for _, k := range keys {
+		kw := words(k)
+		if len(kw) == 0 {
+			continue
+		}
+		for i := 0; i+len(kw) <= len(nameWords); i++ {
+			if slices.EqualFunc(nameWords[i:i+len(kw)], kw, strings.HasPrefix) {
+				return union.UnionCode(c.Unions[k]), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// words returns the lower case words of s
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Apply sets location and pay code on the records according to the config
+func (c *Config) Apply(s2s []spec.S2Spec) error {
+	locs := make(map[int]int, len(c.Locations))
+	for p, loc := range c.Locations {
+		n, err := internal.Str2Person(p)
+		if err != nil {
+			return fmt.Errorf("locations.%s: %w", p, err)
+		}
+		locs[n] = loc
+	}
+	for i := range s2s {
+		s2 := &s2s[i]
+		if loc, ok := locs[s2.PersonNum]; ok {
+			s2.LocNum = loc
+			s2.Source.AddStep("plats %d från [locations] i konfigurationen", loc)
+		} else if c.DefaultLocation > 0 {
+			s2.LocNum = c.DefaultLocation
+		}
+		for i, r := range c.PayCodes {
+			if r.matches(s2) {
+				s2.PayCode = spec.PayCode(r.Code)
+				s2.Source.AddStep("betalkod %d från regel paycode[%d] i konfigurationen", r.Code, i)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (r PayCodeRule) matches(s2 *spec.S2Spec) bool {
+	if r.Person != "" {
+		n, err := internal.Str2Person(r.Person)
+		if err != nil || n != s2.PersonNum {
+			return false
+		}
+	}
+	if r.ZeroAmount && !s2.Amount.IsZero() {
+		return false
+	}
+	return true
+}
+
+// Example is a commented config file with all settings
+const Example = `# unionfees.toml
+
+# Plats (arbetsställe) för medlemmar som inte finns i [locations]
+default_location = 1
+
+# Katalog med egna rapportmallar (*.toml) utöver de inbyggda
+# layouts = "mallar"
+
+# Fil där omgjorda filer sparas för att varna för dubbletter, "-" för ingen.
+# Default historik.jsonl i användarens konfigurationskatalog.
+# history = "historik.jsonl"
+
+# Företagsuppgifter, används i stället för det som står i pdf-filen
+[company]
+# name = "MAGNETBANDS REDOVISNING"
+# orgnum = "556234-4639"
+
+# Förbundsnummer per förbund i rapporten. Namnet matchar om dess ord börjar med
+# nyckelns ord, "IF Metall" och "Metallförbundet" matchar "Metall". Den längsta
+# nyckeln som matchar används.
+[unions]
+"Metall" = 38
+"GS" = 43
+
+# Plats per personnummer
+[locations]
+# "19800101-1234" = 2
+
+# Betalkoder, första regel som matchar används.
+# Utan villkor matchar regeln alla.
+# [[paycode]]
+# zero_amount = true
+# code = 19
+
+# [[paycode]]
+# person = "19800101-1234"
+# code = 3
+
+[output]
+# dir = "utfiler"
+# template = "{{.Union}}-{{.Year}}{{.Period}}.txt"
+encoding = "windows-1252"
+
+# Kolumner i csv-filer från andra lönesystem, med rubrik eller
+# nummer räknat från 1. Visma Löns csv-export känns igen utan detta.
+# [csv]
+# separator = ";"
+# union = "Förbund"        # eller union_name = "IF Metall" för hela filen
+# empno = "Anst.nr"
+# name = "Namn"            # eller first_name och last_name
+# personnum = "Personnummer"
+# amount = "Avgift"
+`
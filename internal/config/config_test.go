@@ -0,0 +1,168 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kmpm/unionfees/internal/union"
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/shopspring/decimal"
+)
+
+const testConfig = `
+default_location = 2
+
+[company]
+name = "MAGNETBANDS REDOVISNING"
+orgnum = "556234-4639"
+
+[unions]
+"Metall" = 38
+
+[locations]
+"19800101-1234" = 3
+
+[[paycode]]
+zero_amount = true
+code = 19
+
+[output]
+dir = "ut"
+encoding = "iso-8859-1"
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoad(t *testing.T) {
+	p := writeConfig(t, testConfig)
+	t.Setenv(EnvPrefix+"OUTPUT_DIR", "från-env")
+
+	cfg, err := Load(p)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := cfg.Check(); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	if cfg.Company.Name != "MAGNETBANDS REDOVISNING" {
+		t.Errorf("Company.Name = %q", cfg.Company.Name)
+	}
+	if cfg.Output.Dir != "från-env" {
+		t.Errorf("Output.Dir = %q, want env override", cfg.Output.Dir)
+	}
+	if code, ok := cfg.UnionCode("IF Metall"); !ok || code != union.CodeIFMetall {
+		t.Errorf("UnionCode() = %d, %v", code, ok)
+	}
+	if _, ok := cfg.UnionCode("Kommunal"); ok {
+		t.Errorf("UnionCode() found unknown union")
+	}
+
+	s2s := []spec.S2Spec{
+		{PersonNum: 8001011234, Amount: decimal.New(100, 0), PayCode: spec.PayCodeAmountPayed},
+		{PersonNum: 8001015678, Amount: decimal.Zero, PayCode: spec.PayCodeAmountPayed},
+	}
+	if err := cfg.Apply(s2s); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if s2s[0].LocNum != 3 || s2s[0].PayCode != spec.PayCodeAmountPayed {
+		t.Errorf("Apply() s2s[0] = %+v", s2s[0])
+	}
+	if s2s[1].LocNum != 2 || s2s[1].PayCode != spec.PayCodeEndEmployment {
+		t.Errorf("Apply() s2s[1] = %+v", s2s[1])
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	if _, err := Load(writeConfig(t, "okand = 1\n")); err == nil {
+		t.Error("Load() expected error for unknown field")
+	}
+	cfg, err := Load(writeConfig(t, "[output]\nencoding = \"utf-8\"\n[[paycode]]\ncode = 2\n"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := cfg.Check(); err == nil {
+		t.Error("Check() expected error for encoding and pay code")
+	}
+}
+
+func TestExample(t *testing.T) {
+	cfg, err := Load(writeConfig(t, Example))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := cfg.Check(); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	if len(cfg.Unions) != 2 {
+		t.Errorf("Unions = %v, want 2", cfg.Unions)
+	}
+}
+
+func TestHistoryPath(t *testing.T) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	abs := filepath.Join(t.TempDir(), "h.jsonl")
+	tests := []struct {
+		history, path, want string
+	}{
+		{"", "", filepath.Join(dir, "unionfees", HistoryFile)},
+		{"-", "/etc/unionfees.toml", ""},
+		{"h.jsonl", filepath.Join("konf", FileName), filepath.Join("konf", "h.jsonl")},
+		{abs, filepath.Join("konf", FileName), abs},
+	}
+	for _, tt := range tests {
+		cfg := &Config{History: tt.history, Path: tt.path}
+		if got := cfg.HistoryPath(); got != tt.want {
+			t.Errorf("HistoryPath() with %q = %q, want %q", tt.history, got, tt.want)
+		}
+	}
+}
+
+func TestUnionCode(t *testing.T) {
+	cfg := &Config{Unions: map[string]int{"Metall": 1, "IF Metall": 2, "GS": 3, "Seko": 4}}
+	tests := []struct {
+		name string
+		want union.UnionCode
+		ok   bool
+	}{
+		{"IF Metall", 2, true},
+		{"if metall", 2, true},
+		{"Metall", 1, true},
+		{"Metallförbundet", 1, true},
+		{"Svenska Metallindustriarbetareförbundet", 1, true},
+		{"Svenska IF Metall avd 3", 2, true},
+		{"GS-facket", 3, true},
+		{"Försäkringsfacket", 0, false},
+		{"Facket för Service och Kommunikation", 0, false},
+		{"Kommunal", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// map order must not matter
+			for range 20 {
+				if got, ok := cfg.UnionCode(tt.name); got != tt.want || ok != tt.ok {
+					t.Fatalf("UnionCode(%q) = %d, %v, want %d, %v", tt.name, got, ok, tt.want, tt.ok)
+				}
+			}
+		})
+	}
+
+	// the table name in the Visma report
+	if got, ok := Default().UnionCode("Metallförbundet"); !ok || got != union.CodeIFMetall {
+		t.Errorf("Default().UnionCode(Metallförbundet) = %d, %v", got, ok)
+	}
+}
@@ -7,18 +7,25 @@ package internal
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/kmpm/unionfees/internal/parser"
 	"github.com/kmpm/unionfees/public/spec"
 	"github.com/shopspring/decimal"
 )
 
 var reParenthesis = regexp.MustCompile(`\((.*?)\)`)
 
+// Str2Person converts a personnummer or organisationsnummer to the 10 digit number
+// used in the files, a 12 digit number has its century removed.
 func Str2Person(v string) (int, error) {
-	v = strings.ReplaceAll(v, "-", "")
+	v = strings.ReplaceAll(strings.TrimSpace(v), "-", "")
+	if len(v) == 12 {
+		v = v[2:]
+	}
 	return strconv.Atoi(v)
 }
 
@@ -46,7 +53,13 @@ func cleanName(name string) string {
 
 func rowS2Data(data []string, spec *spec.S2Spec) error {
 	name := cleanName(data[1])
+	if name != data[1] {
+		spec.Source.AddStep("parentes borttagen ur namn: %q -> %q", data[1], name)
+	}
 	spec.Name = name2LastFirst(name)
+	if spec.Name != name {
+		spec.Source.AddStep("namn omvänt till efternamn förnamn: %q", spec.Name)
+	}
 	n, err := Str2Person(data[2])
 	if err != nil {
 		return err
@@ -57,6 +70,7 @@ func rowS2Data(data []string, spec *spec.S2Spec) error {
 		return err
 	}
 	spec.Amount = f
+	spec.Source.AddStep("betalkod %d som standard", spec.PayCode)
 	return nil
 }
 
@@ -76,9 +90,37 @@ func ConvertS2Data(locNum int, data [][]string) ([]spec.S2Spec, error) {
 	return specs, nil
 }
 
+// ConvertS2Rows converts table rows from file like ConvertS2Data
+// and records where each record came from. Rows that can not be
+// converted are returned as *spec.ParseError.
+func ConvertS2Rows(locNum int, file string, rows []parser.TableRow) ([]spec.S2Spec, error) {
+	specs := []spec.S2Spec{}
+	for _, r := range rows {
+		s := spec.S2Spec{
+			LocNum:  locNum,
+			PayCode: spec.PayCodeAmountPayed,
+			Source: &spec.Provenance{
+				File:  file,
+				Page:  r.Page,
+				Y:     r.Y,
+				Line:  r.Line,
+				Cells: r.Cells,
+				Steps: []string{},
+			},
+		}
+		err := rowS2Data(r.Cells, &s)
+		if err != nil {
+			return specs, &spec.ParseError{File: file, Page: r.Page, Line: r.Line, Row: strings.Join(r.Cells, "; "), Err: err}
+		}
+		specs = append(specs, s)
+	}
+	return specs, nil
+}
+
 type CompanyArgs struct {
 	CompanyNum      int
 	CompanyName     string
+	AccountingType  spec.AccountingType
 	Period          int
 	Year            int
 	TransactionDate time.Time
@@ -89,6 +131,7 @@ func buildS1(args CompanyArgs, locnum int) spec.S1Spec {
 		LocNum:          locnum,
 		CompanyNum:      args.CompanyNum,
 		CompanyName:     args.CompanyName,
+		AccountingType:  args.AccountingType,
 		Period:          args.Period,
 		Year:            args.Year,
 		TransactionDate: args.TransactionDate,
@@ -119,3 +162,17 @@ func BuildLocations(args CompanyArgs, s2s []spec.S2Spec) spec.Locations {
 	}
 	return locs
 }
+
+// AllS2 returns the S2 records of all locations, ordered by location number.
+func AllS2(locs spec.Locations) []spec.S2Spec {
+	keys := make([]int, 0, len(locs))
+	for k := range locs {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	s2s := []spec.S2Spec{}
+	for _, k := range keys {
+		s2s = append(s2s, locs[k].S2...)
+	}
+	return s2s
+}
@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kmpm/unionfees/internal/parser"
+	"github.com/kmpm/unionfees/internal/pdftest"
+	"github.com/kmpm/unionfees/public/spec"
+)
+
+func TestConvertS2Rows(t *testing.T) {
+	r := pdftest.Example()
+	data, err := pdftest.Bytes(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := parser.Read(context.Background(), bytes.NewReader(data), int64(len(data)), parser.Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s2s, err := ConvertS2Rows(1, "fackavgifter.pdf", doc.Tables()["IF Metall"])
+	if err != nil {
+		t.Fatalf("ConvertS2Rows() error = %v", err)
+	}
+	want := []struct {
+		name   string
+		person int
+		amount string
+	}{
+		{"Andersson Anna", 8501011234, "412.00"},
+		{"Bengtsson Bertil ", 7902022345, "1388.50"},
+	}
+	if len(s2s) != len(want) {
+		t.Fatalf("ConvertS2Rows() got %d records, want %d", len(s2s), len(want))
+	}
+	for i, w := range want {
+		s := s2s[i]
+		if s.Name != w.name || s.PersonNum != w.person || s.Amount.StringFixed(2) != w.amount {
+			t.Errorf("record %d = %q %d %s, want %q %d %s", i, s.Name, s.PersonNum, s.Amount.StringFixed(2), w.name, w.person, w.amount)
+		}
+		if s.Source == nil || s.Source.File != "fackavgifter.pdf" || s.Source.Page != 1 {
+			t.Errorf("record %d source = %+v", i, s.Source)
+		}
+	}
+
+	locs := BuildLocations(CompanyArgs{
+		CompanyNum:      5566778899,
+		CompanyName:     r.Company,
+		AccountingType:  spec.AccountingNormal,
+		Period:          4,
+		Year:            25,
+		TransactionDate: time.Date(2025, 4, 25, 0, 0, 0, 0, time.UTC),
+	}, s2s)
+	if got := locs[1].S3.SumAmout; !got.Equal(r.Unions[0].Sum()) {
+		t.Errorf("S3 sum = %s, want %s as in the report", got, r.Unions[0].Sum())
+	}
+}
@@ -0,0 +1,153 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/shopspring/decimal"
+)
+
+type memberKey struct {
+	LocNum    int
+	PersonNum int
+}
+
+// Delta returns the records to submit for typ, given the original submission
+// and the current records for the same period.
+//
+// A correction contains members that are new or whose amount or pay code changed,
+// and members that were removed with a zero amount.
+// A supplement contains new members and the increase for members whose amount went up.
+// For a normal submission current is returned as is.
+func Delta(original, current []spec.S2Spec, typ spec.AccountingType) []spec.S2Spec {
+	if typ == spec.AccountingNormal {
+		return current
+	}
+	orig := make(map[memberKey]spec.S2Spec, len(original))
+	for _, s2 := range original {
+		orig[memberKey{s2.LocNum, s2.PersonNum}] = s2
+	}
+
+	delta := []spec.S2Spec{}
+	seen := map[memberKey]bool{}
+	for _, s2 := range current {
+		key := memberKey{s2.LocNum, s2.PersonNum}
+		seen[key] = true
+		o, ok := orig[key]
+		switch typ {
+		case spec.AccountingCorrection:
+			if !ok || !o.Amount.Equal(s2.Amount) || o.PayCode != s2.PayCode {
+				delta = append(delta, s2)
+			}
+		case spec.AccountingSupplement:
+			if !ok {
+				delta = append(delta, s2)
+			} else if s2.Amount.GreaterThan(o.Amount) {
+				s2.Source = s2.Source.Clone()
+				s2.Source.AddStep("tillägg: %s - %s redan redovisat", s2.Amount.StringFixed(2), o.Amount.StringFixed(2))
+				s2.Amount = s2.Amount.Sub(o.Amount)
+				delta = append(delta, s2)
+			}
+		}
+	}
+	if typ == spec.AccountingCorrection {
+		for _, o := range original {
+			if !seen[memberKey{o.LocNum, o.PersonNum}] {
+				o.Amount = decimal.Zero
+				o.Source = &spec.Provenance{Steps: []string{"borttagen sedan originalet, belopp 0"}}
+				delta = append(delta, o)
+			}
+		}
+	}
+	return delta
+}
+
+// FilterPersons keeps only records for the given person numbers.
+func FilterPersons(s2s []spec.S2Spec, persons []int) []spec.S2Spec {
+	keep := make(map[int]bool, len(persons))
+	for _, p := range persons {
+		keep[p] = true
+	}
+	out := []spec.S2Spec{}
+	for _, s2 := range s2s {
+		if keep[s2.PersonNum] {
+			out = append(out, s2)
+		}
+	}
+	return out
+}
+
+// ParsePersons parses a comma separated list of person numbers.
+func ParsePersons(v string) ([]int, error) {
+	persons := []int{}
+	for _, p := range strings.Split(v, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		n, err := Str2Person(p)
+		if err != nil {
+			return persons, fmt.Errorf("felaktigt personnummer %q: %w", p, err)
+		}
+		persons = append(persons, n)
+	}
+	return persons, nil
+}
+
+// ParseAccountingType accepts the digit or the Swedish name of an accounting type.
+func ParseAccountingType(v string) (spec.AccountingType, error) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "", "0", "ordinarie":
+		return spec.AccountingNormal, nil
+	case "1", "rattelse", "rättelse":
+		return spec.AccountingCorrection, nil
+	case "2", "tillagg", "tillägg":
+		return spec.AccountingSupplement, nil
+	}
+	if n, err := strconv.Atoi(v); err == nil {
+		return spec.AccountingType(n), fmt.Errorf("okänd redovisningstyp: %d", n)
+	}
+	return spec.AccountingNormal, fmt.Errorf("okänd redovisningstyp: %s", v)
+}
+
+// Change is the difference for one member between two submissions.
+// Old is nil for added members and New is nil for removed.
+type Change struct {
+	Old *spec.S2Spec
+	New *spec.S2Spec
+}
+
+// Diff compares two lists of records and returns the members that differ,
+// in the order they appear in a followed by those only in b.
+func Diff(a, b []spec.S2Spec) []Change {
+	bm := make(map[memberKey]*spec.S2Spec, len(b))
+	for i := range b {
+		bm[memberKey{b[i].LocNum, b[i].PersonNum}] = &b[i]
+	}
+	changes := []Change{}
+	seen := map[memberKey]bool{}
+	for i := range a {
+		key := memberKey{a[i].LocNum, a[i].PersonNum}
+		seen[key] = true
+		n, ok := bm[key]
+		if !ok {
+			changes = append(changes, Change{Old: &a[i]})
+			continue
+		}
+		if !n.Amount.Equal(a[i].Amount) || n.PayCode != a[i].PayCode || n.Name != a[i].Name {
+			changes = append(changes, Change{Old: &a[i], New: n})
+		}
+	}
+	for i := range b {
+		if !seen[memberKey{b[i].LocNum, b[i].PersonNum}] {
+			changes = append(changes, Change{New: &b[i]})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/shopspring/decimal"
+)
+
+func TestDelta(t *testing.T) {
+	original := []spec.S2Spec{
+		{LocNum: 1, PersonNum: 1, Amount: decimal.RequireFromString("100"), PayCode: spec.PayCodeAmountPayed},
+		{LocNum: 1, PersonNum: 2, Amount: decimal.RequireFromString("200"), PayCode: spec.PayCodeAmountPayed},
+		{LocNum: 1, PersonNum: 3, Amount: decimal.RequireFromString("300"), PayCode: spec.PayCodeAmountPayed},
+	}
+	current := []spec.S2Spec{
+		{LocNum: 1, PersonNum: 1, Amount: decimal.RequireFromString("100"), PayCode: spec.PayCodeAmountPayed},
+		{LocNum: 1, PersonNum: 2, Amount: decimal.RequireFromString("250"), PayCode: spec.PayCodeAmountPayed},
+		{LocNum: 1, PersonNum: 4, Amount: decimal.RequireFromString("400"), PayCode: spec.PayCodeAmountPayed},
+	}
+
+	tests := []struct {
+		name string
+		typ  spec.AccountingType
+		want map[int]string
+	}{
+		{"normal", spec.AccountingNormal, map[int]string{1: "100", 2: "250", 4: "400"}},
+		{"correction", spec.AccountingCorrection, map[int]string{2: "250", 4: "400", 3: "0"}},
+		{"supplement", spec.AccountingSupplement, map[int]string{2: "50", 4: "400"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Delta(original, current, tt.typ)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Delta() got %d records, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for _, s2 := range got {
+				w, ok := tt.want[s2.PersonNum]
+				if !ok {
+					t.Errorf("Delta() unexpected person %d", s2.PersonNum)
+					continue
+				}
+				if !s2.Amount.Equal(decimal.RequireFromString(w)) {
+					t.Errorf("Delta() person %d amount = %s, want %s", s2.PersonNum, s2.Amount, w)
+				}
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := []spec.S2Spec{
+		{LocNum: 1, PersonNum: 1, Amount: decimal.RequireFromString("100")},
+		{LocNum: 1, PersonNum: 2, Amount: decimal.RequireFromString("200")},
+	}
+	b := []spec.S2Spec{
+		{LocNum: 1, PersonNum: 2, Amount: decimal.RequireFromString("210")},
+		{LocNum: 1, PersonNum: 3, Amount: decimal.RequireFromString("300")},
+	}
+	got := Diff(a, b)
+	if len(got) != 3 {
+		t.Fatalf("Diff() got %d changes, want 3", len(got))
+	}
+	if got[0].Old.PersonNum != 1 || got[0].New != nil {
+		t.Errorf("Diff()[0] want removed person 1, got %+v", got[0])
+	}
+	if got[1].Old.PersonNum != 2 || got[1].New == nil {
+		t.Errorf("Diff()[1] want changed person 2, got %+v", got[1])
+	}
+	if got[2].Old != nil || got[2].New.PersonNum != 3 {
+		t.Errorf("Diff()[2] want added person 3, got %+v", got[2])
+	}
+}
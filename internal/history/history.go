@@ -0,0 +1,204 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+// Package history records the input files that have been converted, so
+// that the same file, or another report for the same company and period,
+// can be detected before it is sent to a union twice.
+//
+// The history is a file with one json object per line that is only
+// appended to, it can be shared by the cli and the server.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kmpm/unionfees/public/spec"
+)
+
+// Entry is a converted input file
+type Entry struct {
+	Time       time.Time           `json:"time"`
+	Hash       string              `json:"sha256"`
+	File       string              `json:"file"`
+	Company    string              `json:"company"`
+	OrgNum     string              `json:"orgnum"`
+	Year       int                 `json:"year"`
+	Period     int                 `json:"period"`
+	Accounting spec.AccountingType `json:"accountingType"`
+	Test       bool                `json:"test,omitempty"`
+	// Unions are the union numbers that files were produced for
+	Unions []int    `json:"unions"`
+	Files  []string `json:"files"`
+}
+
+// Match is an earlier entry that e is likely a duplicate of
+type Match struct {
+	Entry
+	// SameFile is true if the content is the same, otherwise it is
+	// the same company and period
+	SameFile bool
+}
+
+func (m Match) String() string {
+	what := fmt.Sprintf("samma företag och period %02d/%02d", m.Period, m.Year)
+	if m.SameFile {
+		what = "samma fil"
+	}
+	return fmt.Sprintf("%s har redan gjorts om %s (%s), då skapades %s",
+		filepath.Base(m.File), m.Time.Local().Format("2006-01-02 15:04"), what, strings.Join(m.Files, ", "))
+}
+
+// Store is a history file
+type Store struct {
+	path string
+	mu   *sync.Mutex // shared by the stores of the same file
+}
+
+// mutexes has a mutex per history file, as a file lock does not
+// serialize the goroutines of one process on every platform
+var mutexes sync.Map
+
+// Open returns the history in the file at path, the file is created
+// by the first Add
+func Open(path string) *Store {
+	key := path
+	if abs, err := filepath.Abs(path); err == nil {
+		key = abs
+	}
+	mu, _ := mutexes.LoadOrStore(key, &sync.Mutex{})
+	return &Store{path: path, mu: mu.(*sync.Mutex)}
+}
+
+// Path is the file of the history
+func (s *Store) Path() string { return s.path }
+
+// Find returns the earlier entries that e duplicates, oldest first.
+// An entry is a duplicate if it produced files for one of the same
+// unions and either has the same hash or is an ordinary accounting
+// for the same company and period. Test submissions are only compared
+// with each other.
+func (s *Store) Find(e Entry) ([]Match, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.find(e)
+}
+
+func (s *Store) find(e Entry) ([]Match, error) {
+	f, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var matches []Match
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	line := 0
+	for sc.Scan() {
+		line++
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var old Entry
+		if err := json.Unmarshal(sc.Bytes(), &old); err != nil {
+			return matches, fmt.Errorf("%s rad %d: %w", s.path, line, err)
+		}
+		if old.Test != e.Test || !slices.ContainsFunc(old.Unions, func(u int) bool { return slices.Contains(e.Unions, u) }) {
+			continue
+		}
+		switch {
+		case e.Hash != "" && old.Hash == e.Hash:
+			matches = append(matches, Match{Entry: old, SameFile: true})
+		case e.OrgNum != "" && old.OrgNum == e.OrgNum && old.Year == e.Year && old.Period == e.Period &&
+			old.Accounting == spec.AccountingNormal && e.Accounting == spec.AccountingNormal:
+			matches = append(matches, Match{Entry: old})
+		}
+	}
+	return matches, sc.Err()
+}
+
+// Add appends e to the history, Time is set if zero
+func (s *Store) Add(e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(e)
+}
+
+// Record finds the entries that e duplicates, as Find, and adds e if
+// decide returns true. The history is locked from the search until e is
+// added, also against other processes, so that two conversions of the
+// same report can not both miss each other. decide gets the error if the
+// history can not be locked or read, e is then still added if it
+// returns true. The returned error is from adding e.
+func (s *Store) Record(e Entry, decide func(matches []Match, err error) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lock()
+	if err != nil {
+		if decide(nil, err) {
+			return s.add(e)
+		}
+		return nil
+	}
+	defer unlock()
+	if decide(s.find(e)) {
+		return s.add(e)
+	}
+	return nil
+}
+
+// lock takes the lock file next to the history
+func (s *Store) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(s.path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("låsa %s: %w", f.Name(), err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+func (s *Store) add(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	// a single write so that lines from other processes are not mixed
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
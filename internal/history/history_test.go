@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kmpm/unionfees/public/spec"
+)
+
+func TestFind(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "ny", "historik.jsonl"))
+	if m, err := s.Find(Entry{Hash: "a"}); err != nil || len(m) != 0 {
+		t.Fatalf("Find() without file = %v, %v", m, err)
+	}
+	first := Entry{
+		Time: time.Date(2025, 4, 28, 14, 2, 0, 0, time.Local), Hash: "a", File: "/lön/fackavgifter.pdf",
+		OrgNum: "556677-8899", Year: 25, Period: 4, Unions: []int{38, 43}, Files: []string{"GS-2504.txt", "IF Metall-2504.txt"},
+	}
+	for _, e := range []Entry{first, {Hash: "b", OrgNum: "556677-8899", Year: 25, Period: 3, Unions: []int{38}}} {
+		if err := s.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		e        Entry
+		want     int
+		sameFile bool
+	}{
+		{"samma fil", Entry{Hash: "a", Year: 25, Period: 5, Unions: []int{38}}, 1, true},
+		{"samma period", Entry{Hash: "c", OrgNum: "556677-8899", Year: 25, Period: 4, Unions: []int{43}}, 1, false},
+		{"annat förbund", Entry{Hash: "a", Unions: []int{1}}, 0, false},
+		{"rättelse", Entry{Hash: "c", OrgNum: "556677-8899", Year: 25, Period: 4, Unions: []int{38},
+			Accounting: spec.AccountingCorrection}, 0, false},
+		{"test", Entry{Hash: "a", Unions: []int{38}, Test: true}, 0, false},
+		{"annan period", Entry{Hash: "c", OrgNum: "556677-8899", Year: 25, Period: 6, Unions: []int{38}}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := s.Find(tt.e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(m) != tt.want || (len(m) > 0 && m[0].SameFile != tt.sameFile) {
+				t.Errorf("Find() = %+v, want %d with SameFile %v", m, tt.want, tt.sameFile)
+			}
+		})
+	}
+
+	m, _ := s.Find(Entry{Hash: "a", Unions: []int{38}})
+	want := "fackavgifter.pdf har redan gjorts om 2025-04-28 14:02 (samma fil), då skapades GS-2504.txt, IF Metall-2504.txt"
+	if len(m) != 1 || m[0].String() != want {
+		t.Errorf("String() = %v, want %q", m, want)
+	}
+}
+
+func TestFindInvalid(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "historik.jsonl")
+	if err := os.WriteFile(p, []byte("{}\ninte json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(p).Find(Entry{}); err == nil || !strings.Contains(err.Error(), "rad 2") {
+		t.Errorf("Find() error = %v, want error on rad 2", err)
+	}
+}
+
+func TestRecordConcurrent(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "ny", "historik.jsonl")
+	e := Entry{Hash: "a", OrgNum: "556677-8899", Year: 25, Period: 4, Unions: []int{38}}
+
+	const n = 8
+	var wg sync.WaitGroup
+	var added, dups atomic.Int32
+	for i := range n {
+		// every other store has a mutex of its own, as in another
+		// process, so that only the file lock keeps them apart
+		s := Open(p)
+		if i%2 == 1 {
+			s = &Store{path: p, mu: &sync.Mutex{}}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.Record(e, func(m []Match, err error) bool {
+				if err != nil {
+					t.Error(err)
+				}
+				if len(m) > 0 {
+					dups.Add(1)
+					return false
+				}
+				// the files are written here, give the others time to look
+				time.Sleep(10 * time.Millisecond)
+				added.Add(1)
+				return true
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if added.Load() != 1 || dups.Load() != n-1 {
+		t.Errorf("%d added and %d duplicates, want 1 and %d", added.Load(), dups.Load(), n-1)
+	}
+	if m, err := Open(p).Find(e); err != nil || len(m) != 1 {
+		t.Errorf("Find() = %v, %v, want the one added", m, err)
+	}
+}
@@ -0,0 +1,15 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+//go:build !unix && !windows
+
+package history
+
+import "os"
+
+// without file locks only the conversions in this process are serialized
+
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
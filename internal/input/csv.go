@@ -0,0 +1,253 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package input
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kmpm/unionfees/internal/parser"
+	"github.com/kmpm/unionfees/public/spec"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// CSVMapping tells which columns of a csv file hold what. A column is
+// given by its header, or by its number counted from 1 if the file has
+// no header row.
+type CSVMapping struct {
+	// Separator between columns, default semicolon
+	Separator string `toml:"separator"`
+	// Union is the column with the union name, or UnionName is used
+	// for all rows if the file only has one union
+	Union     string `toml:"union"`
+	UnionName string `toml:"union_name"`
+	EmpNo     string `toml:"empno"`
+	// Name is "Förnamn Efternamn", or use FirstName and LastName
+	Name      string `toml:"name"`
+	FirstName string `toml:"first_name"`
+	LastName  string `toml:"last_name"`
+	PersonNum string `toml:"personnum"`
+	Amount    string `toml:"amount"`
+}
+
+// Check returns an error if required columns are missing
+func (m CSVMapping) Check() error {
+	var errs []error
+	if len([]rune(m.Separator)) > 1 {
+		errs = append(errs, fmt.Errorf("separator måste vara ett tecken, inte %q", m.Separator))
+	}
+	if m.Union == "" && m.UnionName == "" {
+		errs = append(errs, errors.New("union eller union_name måste anges"))
+	}
+	if m.Name == "" && (m.FirstName == "" || m.LastName == "") {
+		errs = append(errs, errors.New("name eller first_name och last_name måste anges"))
+	}
+	if m.PersonNum == "" {
+		errs = append(errs, errors.New("personnum måste anges"))
+	}
+	if m.Amount == "" {
+		errs = append(errs, errors.New("amount måste anges"))
+	}
+	return errors.Join(errs...)
+}
+
+func (m CSVMapping) columns() []string {
+	return []string{m.Union, m.EmpNo, m.Name, m.FirstName, m.LastName, m.PersonNum, m.Amount}
+}
+
+// hasHeader is true if any column is given by its header
+func (m CSVMapping) hasHeader() bool {
+	return slices.ContainsFunc(m.columns(), func(c string) bool {
+		_, err := strconv.Atoi(c)
+		return c != "" && err != nil
+	})
+}
+
+// CSV reads a semicolon or comma separated export with the columns given
+// by Mapping. Rows without personnummer, e.g. sums, are skipped.
+type CSV struct {
+	Mapping CSVMapping
+	name    string
+}
+
+// VismaCSV reads the Fackavgifter report exported from Visma Lön as a
+// file, it has the same columns as the pdf and one row per member.
+func VismaCSV() *CSV {
+	return &CSV{
+		name: "visma-csv",
+		Mapping: CSVMapping{
+			Separator: ";",
+			Union:     "Fackförbund",
+			EmpNo:     "Anst.nr",
+			Name:      "Namn",
+			PersonNum: "Personnummer",
+			Amount:    "Belopp",
+		},
+	}
+}
+
+func (c *CSV) Name() string {
+	if c.name != "" {
+		return c.name
+	}
+	return "csv"
+}
+
+func (c *CSV) Detect(filename string, data []byte) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv", ".txt", ".skv":
+	default:
+		return false
+	}
+	if !c.Mapping.hasHeader() {
+		return c.name == ""
+	}
+	r := c.reader(data)
+	header, err := r.Read()
+	if err != nil {
+		return false
+	}
+	_, err = c.indexes(header)
+	return err == nil
+}
+
+func (c *CSV) reader(data []byte) *csv.Reader {
+	r := csv.NewReader(bytes.NewReader(decode(data)))
+	r.Comma = ';'
+	if s, _ := utf8.DecodeRuneInString(c.Mapping.Separator); s != utf8.RuneError {
+		r.Comma = s
+	}
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+	return r
+}
+
+// decode returns data as utf-8, files from Windows programs are often
+// in windows-1252
+func decode(data []byte) []byte {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(data) {
+		return data
+	}
+	out, err := charmap.Windows1252.NewDecoder().Bytes(data)
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+// indexes returns the 0 based index of each mapped column, -1 if not used
+func (c *CSV) indexes(header []string) ([]int, error) {
+	var missing []string
+	idx := []int{}
+	for _, col := range c.Mapping.columns() {
+		i := -1
+		if n, err := strconv.Atoi(col); err == nil {
+			i = n - 1
+		} else if col != "" {
+			i = slices.IndexFunc(header, func(h string) bool { return strings.EqualFold(strings.TrimSpace(h), col) })
+			if i < 0 {
+				missing = append(missing, col)
+			}
+		}
+		idx = append(idx, i)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("kolumner saknas: %s", strings.Join(missing, ", "))
+	}
+	return idx, nil
+}
+
+func (c *CSV) Read(_ context.Context, data []byte) (*Input, error) {
+	if err := c.Mapping.Check(); err != nil {
+		return nil, err
+	}
+	r := c.reader(data)
+	var idx []int
+	if !c.Mapping.hasHeader() {
+		idx, _ = c.indexes(nil)
+	}
+	in := &Input{Format: c.Name(), Tables: map[string][]parser.TableRow{}}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			return nil, &spec.ParseError{Line: pe.Line, Err: pe.Err}
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		if idx == nil {
+			if idx, err = c.indexes(record); err != nil {
+				return nil, &spec.ParseError{Line: line, Err: err}
+			}
+			continue
+		}
+		if slices.IndexFunc(record, func(s string) bool { return strings.TrimSpace(s) != "" }) < 0 {
+			continue
+		}
+		cell := func(i int) (string, error) {
+			if idx[i] < 0 {
+				return "", nil
+			}
+			if idx[i] >= len(record) {
+				return "", &spec.ParseError{Line: line, Err: fmt.Errorf("kolumn %d saknas", idx[i]+1)}
+			}
+			return strings.TrimSpace(record[idx[i]]), nil
+		}
+		var cells [7]string
+		for i := range cells {
+			if cells[i], err = cell(i); err != nil {
+				return nil, err
+			}
+		}
+		unionName, empNo, name, first, last, pnr, amount := cells[0], cells[1], cells[2], cells[3], cells[4], cells[5], cells[6]
+		if pnr == "" {
+			// sums and other rows that are not members
+			continue
+		}
+		if unionName == "" {
+			unionName = c.Mapping.UnionName
+		}
+		if unionName == "" {
+			return nil, &spec.ParseError{Line: line, Err: errors.New("förbund saknas")}
+		}
+		if name == "" {
+			name = strings.TrimSpace(first + " " + last)
+		}
+		in.Tables[unionName] = append(in.Tables[unionName], parser.TableRow{
+			Line:  line,
+			Cells: []string{empNo, name, pnr, normalizeAmount(amount)},
+		})
+	}
+	if idx == nil {
+		return nil, errors.New("filen är tom")
+	}
+	return in, nil
+}
+
+// normalizeAmount removes thousand separators, 1 234,50 becomes 1234,50
+func normalizeAmount(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == ' ' {
+			return -1
+		}
+		return r
+	}, s)
+}
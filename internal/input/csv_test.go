@@ -0,0 +1,198 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package input
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kmpm/unionfees/internal"
+	"github.com/kmpm/unionfees/internal/pdftest"
+	"github.com/kmpm/unionfees/public/spec"
+	"golang.org/x/text/encoding/charmap"
+)
+
+var testReport = pdftest.Example()
+
+// vismaCSV writes r like the csv export of Visma Lön
+func vismaCSV(r pdftest.Report) string {
+	var b strings.Builder
+	b.WriteString("Fackförbund;Anst.nr;Namn;Personnummer;Belopp\r\n")
+	for _, u := range r.Unions {
+		for _, m := range u.Members {
+			fmt.Fprintf(&b, "%s;%s;%s;%s;%s\r\n", u.Name, m.EmpNo, m.Name, m.PersonNum, pdftest.Amount(m.Amount))
+		}
+		fmt.Fprintf(&b, "Summa %s;;;;%s\r\n", u.Name, pdftest.Amount(u.Sum()))
+	}
+	return b.String()
+}
+
+// records converts every union and drops the provenance
+func records(t *testing.T, in *Input) map[string][]spec.S2Spec {
+	t.Helper()
+	out := map[string][]spec.S2Spec{}
+	for k, rows := range in.Tables {
+		s2s, err := internal.ConvertS2Rows(1, "fil", rows)
+		if err != nil {
+			t.Fatalf("ConvertS2Rows(%s) error = %v", k, err)
+		}
+		for i := range s2s {
+			s2s[i].Source = nil
+		}
+		out[k] = s2s
+	}
+	return out
+}
+
+func TestSameRecordsAsPdf(t *testing.T) {
+	data, err := pdftest.Bytes(testReport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapters := Adapters(nil, nil)
+	pdf, err := Read(context.Background(), "fackavgifter.pdf", data, "", adapters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pdf.Format != "pdf" || pdf.CompanyName != "Exempel AB" || pdf.OrgNum != "556677-8899" {
+		t.Errorf("pdf = %s, %q, %q", pdf.Format, pdf.CompanyName, pdf.OrgNum)
+	}
+	want := records(t, pdf)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"utf-8", []byte(vismaCSV(testReport))},
+		{"bom", []byte("\xef\xbb\xbf" + vismaCSV(testReport))},
+	}
+	win, err := charmap.Windows1252.NewEncoder().String(vismaCSV(testReport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests = append(tests, struct {
+		name string
+		data []byte
+	}{"windows-1252", []byte(win)})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, err := Read(context.Background(), "fackavgifter.csv", tt.data, "", adapters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if in.Format != "visma-csv" || in.Doc != nil {
+				t.Errorf("Format = %s, Doc = %v", in.Format, in.Doc)
+			}
+			if got := records(t, in); !reflect.DeepEqual(got, want) {
+				t.Errorf("records = %v\nwant %v", got, want)
+			}
+			if got := in.Tables["IF Metall"][1].Line; got != 3 {
+				t.Errorf("Line = %d, want 3", got)
+			}
+		})
+	}
+}
+
+func TestCSVMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping CSVMapping
+		data    string
+		want    map[string][][]string
+		wantErr string
+	}{
+		{
+			name: "rubriker",
+			mapping: CSVMapping{Separator: ",", Union: "Förbund", FirstName: "Förnamn", LastName: "Efternamn",
+				PersonNum: "Pnr", Amount: "Avgift"},
+			data: "Pnr,Efternamn,Förnamn,Förbund,Avgift\n" +
+				"850101-1234,Andersson,Anna,IF Metall,\"1 412,00\"\n" +
+				"\n" +
+				",,,,1412\n",
+			want: map[string][][]string{"IF Metall": {{"", "Anna Andersson", "850101-1234", "1412,00"}}},
+		},
+		{
+			name:    "nummer",
+			mapping: CSVMapping{UnionName: "GS", EmpNo: "1", Name: "2", PersonNum: "3", Amount: "5"},
+			data:    "7;Åsa Öberg;900303-3456;x;295.25\n",
+			want:    map[string][][]string{"GS": {{"7", "Åsa Öberg", "900303-3456", "295.25"}}},
+		},
+		{
+			name:    "kolumn saknas",
+			mapping: CSVMapping{Union: "Förbund", Name: "Namn", PersonNum: "Pnr", Amount: "Avgift"},
+			data:    "Förbund;Namn;Pnr\n",
+			wantErr: "rad 1: kolumner saknas: Avgift",
+		},
+		{
+			name:    "för få kolumner",
+			mapping: CSVMapping{UnionName: "GS", Name: "1", PersonNum: "2", Amount: "3"},
+			data:    "Anna;850101-1234;412\nBertil;790202-2345\n",
+			wantErr: "rad 2: kolumn 3 saknas",
+		},
+		{
+			name:    "ofullständig",
+			mapping: CSVMapping{Name: "Namn"},
+			wantErr: "union eller union_name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &CSV{Mapping: tt.mapping}
+			in, err := a.Read(context.Background(), []byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Read() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if got := in.Cells(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	mapping := &CSVMapping{UnionName: "GS", Name: "1", PersonNum: "2", Amount: "3"}
+	tests := []struct {
+		name     string
+		filename string
+		data     string
+		format   string
+		mapping  *CSVMapping
+		want     string
+		wantErr  string
+	}{
+		{"visma", "a.csv", vismaCSV(testReport), "", nil, "visma-csv", ""},
+		{"okänd csv", "a.csv", "Anna;850101-1234;412\n", "", nil, "", "känner inte igen formatet på a.csv"},
+		{"egen csv", "a.csv", "Anna;850101-1234;412\n", "", mapping, "csv", ""},
+		{"angivet format", "-", "Anna;850101-1234;412\n", "csv", mapping, "csv", ""},
+		{"okänt format", "a.csv", "", "hogia", mapping, "", `okänt format "hogia", använd pdf, xlsx, visma-csv, csv`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, err := Read(context.Background(), tt.filename, []byte(tt.data), tt.format, Adapters(nil, tt.mapping))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Read() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if in.Format != tt.want {
+				t.Errorf("Format = %s, want %s", in.Format, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+// Package input reads union fee data from payroll systems. Every adapter
+// returns the members per union as table rows in the cell order expected
+// by internal.ConvertS2Rows, so all sources give the same S2 records.
+package input
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kmpm/unionfees/internal"
+	"github.com/kmpm/unionfees/internal/parser"
+	"github.com/kmpm/unionfees/public/spec"
+)
+
+// Input is what was read from a payroll export
+type Input struct {
+	// Format is the name of the adapter that read it
+	Format string
+	// CompanyName and OrgNum are empty if not found in the input
+	CompanyName string
+	OrgNum      string
+	// Tables are the rows per union name, cells in parser field order
+	Tables map[string][]parser.TableRow
+	// Doc is the parsed pdf, nil for other formats
+	Doc *parser.Document
+	// Records are set instead of being converted from Tables when the
+	// input is a reviewed member list
+	Records map[string][]spec.S2Spec
+}
+
+// S2 returns the records of a union read from file. Reviewed is true if
+// they come from a reviewed member list, pay codes and locations are then
+// already set and config rules should not be applied again.
+func (in *Input) S2(file, union string) (s2s []spec.S2Spec, reviewed bool, err error) {
+	if recs, ok := in.Records[union]; ok {
+		s2s = make([]spec.S2Spec, len(recs))
+		for i, s2 := range recs {
+			s2.Source = s2.Source.Clone()
+			if s2.Source != nil {
+				s2.Source.File = file
+			}
+			s2s[i] = s2
+		}
+		return s2s, true, nil
+	}
+	s2s, err = internal.ConvertS2Rows(1, file, in.Tables[union])
+	return s2s, false, err
+}
+
+// Cells returns the cells of the rows per union
+func (in *Input) Cells() map[string][][]string {
+	tables := map[string][][]string{}
+	for k, rows := range in.Tables {
+		cells := make([][]string, len(rows))
+		for i, r := range rows {
+			cells[i] = r.Cells
+		}
+		tables[k] = cells
+	}
+	return tables
+}
+
+// Adapter reads the export of a payroll system
+type Adapter interface {
+	// Name is used with -format and shown to the user
+	Name() string
+	// Detect tells if data from filename looks like something the adapter reads
+	Detect(filename string, data []byte) bool
+	Read(ctx context.Context, data []byte) (*Input, error)
+}
+
+// Adapters returns the adapters in the order they are tried. The pdf
+// adapter uses templates in addition to the built in ones and the
+// generic csv adapter is only included if mapping is set.
+func Adapters(templates []*parser.Template, mapping *CSVMapping) []Adapter {
+	adapters := []Adapter{&PDF{Templates: templates}, &XLSX{}, VismaCSV()}
+	if mapping != nil {
+		adapters = append(adapters, &CSV{Mapping: *mapping})
+	}
+	return adapters
+}
+
+// Extensions are the file name extensions that the built in adapters
+// read, with the dot and in lower case. A pdf can also be read from the
+// json of a parsed document.
+var Extensions = []string{".pdf", ".json", ".xlsx", ".csv", ".txt", ".skv"}
+
+// Names returns the names of adapters
+func Names(adapters []Adapter) []string {
+	names := make([]string, len(adapters))
+	for i, a := range adapters {
+		names[i] = a.Name()
+	}
+	return names
+}
+
+// Read reads data with the adapter called format, or the first adapter
+// that detects it if format is empty
+func Read(ctx context.Context, filename string, data []byte, format string, adapters []Adapter) (*Input, error) {
+	i := slices.IndexFunc(adapters, func(a Adapter) bool {
+		if format != "" {
+			return a.Name() == format
+		}
+		return a.Detect(filename, data)
+	})
+	switch {
+	case i >= 0:
+		return adapters[i].Read(ctx, data)
+	case format != "":
+		return nil, fmt.Errorf("okänt format %q, använd %s", format, strings.Join(Names(adapters), ", "))
+	default:
+		return nil, fmt.Errorf("känner inte igen formatet på %s, ange det med -format (%s)",
+			filepath.Base(filename), strings.Join(Names(adapters), ", "))
+	}
+}
+
+// PDF reads the Visma Lön report as a pdf, or as a document saved with
+// dump -json
+type PDF struct {
+	Templates []*parser.Template
+	Limits    parser.Limits
+	// Parse is used instead of parser.Read if set, e.g. to
+	// parse in a worker process
+	Parse func(ctx context.Context, data []byte, lim parser.Limits) (*parser.Document, error)
+}
+
+func (p *PDF) Name() string { return "pdf" }
+
+func (p *PDF) Detect(filename string, data []byte) bool {
+	return bytes.HasPrefix(data, []byte("%PDF")) || isDocument(data)
+}
+
+func isDocument(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+func (p *PDF) Read(ctx context.Context, data []byte) (*Input, error) {
+	var doc *parser.Document
+	var err error
+	switch {
+	case isDocument(data):
+		doc, err = parser.Load(bytes.NewReader(data))
+	case p.Parse != nil:
+		doc, err = p.Parse(ctx, data, p.Limits)
+	default:
+		doc, err = parser.Read(ctx, bytes.NewReader(data), int64(len(data)), p.Limits)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Pages) == 0 {
+		return nil, fmt.Errorf("pdf saknar sidor")
+	}
+	doc.Template = parser.SelectTemplate(doc, append(parser.Templates(), p.Templates...))
+	name, num := doc.Company()
+	return &Input{
+		Format:      p.Name(),
+		CompanyName: name,
+		OrgNum:      num,
+		Tables:      doc.Tables(),
+		Doc:         doc,
+	}, nil
+}
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package input
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/kmpm/unionfees/internal/parser"
+	"github.com/kmpm/unionfees/internal/xlsx"
+	"github.com/kmpm/unionfees/public/spec"
+)
+
+// XLSX reads member lists exported with convert -xlsx and reviewed in
+// Excel, one sheet per union
+type XLSX struct {
+	// Limits.MaxSize is the most any part of the workbook may be unpacked
+	Limits parser.Limits
+}
+
+func (x *XLSX) Name() string { return "xlsx" }
+
+func (x *XLSX) Detect(filename string, data []byte) bool {
+	// xlsx is a zip file
+	return strings.EqualFold(filepath.Ext(filename), ".xlsx") || bytes.HasPrefix(data, []byte("PK\x03\x04"))
+}
+
+func (x *XLSX) Read(_ context.Context, data []byte) (*Input, error) {
+	if x.Limits.MaxSize > 0 && int64(len(data)) > x.Limits.MaxSize {
+		return nil, &limitError{fmt.Errorf("xlsx-filen är för stor: %d byte, max %d", len(data), x.Limits.MaxSize)}
+	}
+	lists, err := xlsx.ReadMembers(bytes.NewReader(data), int64(len(data)), x.Limits.MaxSize)
+	if errors.Is(err, xlsx.ErrTooLarge) {
+		return nil, &limitError{err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	in := &Input{
+		Format:  x.Name(),
+		Tables:  map[string][]parser.TableRow{},
+		Records: map[string][]spec.S2Spec{},
+	}
+	for _, l := range lists {
+		in.Records[l.Union] = l.S2
+		rows := []parser.TableRow{}
+		for _, s2 := range l.S2 {
+			rows = append(rows, parser.TableRow{Line: s2.Source.Line, Cells: s2.Source.Cells})
+		}
+		in.Tables[l.Union] = rows
+	}
+	return in, nil
+}
+
+// limitError is over the limits for something else than a pdf,
+// it is a parser.ErrLimit with its own message
+type limitError struct{ err error }
+
+func (e *limitError) Error() string        { return e.err.Error() }
+func (e *limitError) Unwrap() error        { return e.err }
+func (e *limitError) Is(target error) bool { return target == parser.ErrLimit }
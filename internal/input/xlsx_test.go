@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package input
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kmpm/unionfees/internal/parser"
+	"github.com/kmpm/unionfees/internal/xlsx"
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/shopspring/decimal"
+)
+
+func TestXLSXLimits(t *testing.T) {
+	l := xlsx.Members{Union: "GS"}
+	for range 2000 {
+		l.S2 = append(l.S2, spec.S2Spec{Name: "Andersson Anna", PersonNum: 8501011234, Amount: decimal.New(412, 0), LocNum: 1})
+	}
+	var buf bytes.Buffer
+	if err := xlsx.WriteMembers(&buf, []xlsx.Members{l}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	tests := []struct {
+		name string
+		max  int64
+		want error
+	}{
+		{"utan gräns", 0, nil},
+		{"filen", int64(len(data)) - 1, parser.ErrLimit},
+		// the sheet is many times larger unpacked
+		{"uppackad", int64(len(data)) + 1, parser.ErrLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := &XLSX{Limits: parser.Limits{MaxSize: tt.max}}
+			_, err := x.Read(context.Background(), data)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Read() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
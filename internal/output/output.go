@@ -0,0 +1,209 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+// Package output names and writes the files produced for the unions.
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// DefaultTemplate gives the same names as earlier versions, e.g. "IF Metall-2504.txt"
+const DefaultTemplate = `{{.Union}}{{if .Test}}-test{{end}}-{{.Year}}{{.Period}}{{if .Location}}-{{.Location}}{{end}}.txt`
+
+// ErrExists is returned when a file exists and overwrite was not allowed
+var ErrExists = errors.New("filen finns redan")
+
+// NameData is what a file name template can use.
+type NameData struct {
+	Union    string // union name as in the report, e.g. "IF Metall"
+	Code     int    // union number
+	Company  string
+	OrgNum   string
+	Year     string // YY
+	Period   string // MM
+	Location string // NNNN, empty when all locations are in the same file
+	Test     bool   // file is for a test submission
+	Env      string // "test" or "prod"
+}
+
+// NewNameData formats the numeric parts as they are used in the union files.
+// location 0 means all locations.
+func NewNameData(unionName string, code int, company, orgnum string, year, period, location int, test bool) NameData {
+	d := NameData{
+		Union:   unionName,
+		Code:    code,
+		Company: company,
+		OrgNum:  orgnum,
+		Year:    fmt.Sprintf("%02d", year),
+		Period:  fmt.Sprintf("%02d", period),
+		Test:    test,
+		Env:     "prod",
+	}
+	if location > 0 {
+		d.Location = fmt.Sprintf("%04d", location)
+	}
+	if test {
+		d.Env = "test"
+	}
+	return d
+}
+
+var nameReplacer = strings.NewReplacer(
+	"/", "_", "\\", "_", ":", "_", "*", "_", "?", "_",
+	"\"", "_", "<", "_", ">", "_", "|", "_",
+)
+
+// FileName executes tmpl with data and returns a name safe to use as a file name.
+func FileName(tmpl string, data NameData) (string, error) {
+	if tmpl == "" {
+		tmpl = DefaultTemplate
+	}
+	t, err := template.New("filename").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("felaktig filnamnsmall: %w", err)
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("felaktig filnamnsmall: %w", err)
+	}
+	name := strings.TrimSpace(nameReplacer.Replace(sb.String()))
+	if name == "" || name == "." || name == ".." {
+		return "", fmt.Errorf("filnamnsmallen gav ett tomt filnamn")
+	}
+	return name, nil
+}
+
+// WriteFile writes to path through a temporary file in the same directory
+// that is renamed into place when write has succeeded, so an existing file
+// is never left half written. Unless overwrite is set ErrExists is returned
+// if path already exists.
+func WriteFile(path string, overwrite bool, write func(w io.Writer) error) error {
+	if !overwrite {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s: %w", path, ErrExists)
+		}
+	}
+	tmp, err := writeTemp(path, write)
+	if err != nil {
+		return err
+	}
+	// remove the temp file on any error below, after a rename this fails silently
+	defer os.Remove(tmp)
+	return place(tmp, path, overwrite)
+}
+
+// File is a file for WriteFiles
+type File struct {
+	Path string
+	Data []byte
+}
+
+// WriteFiles writes all files or none. Unless overwrite is set every
+// path is checked before anything is written, the files are written to
+// temporary names and put in place when all are written. If a file can
+// not be put in place, e.g. as it has been created since the check, the
+// files already put in place are removed again. The error is a
+// *fs.PathError for the file that failed.
+func WriteFiles(files []File, overwrite bool) error {
+	if !overwrite {
+		for _, f := range files {
+			if _, err := os.Stat(f.Path); err == nil {
+				return &fs.PathError{Op: "write", Path: f.Path, Err: ErrExists}
+			}
+		}
+	}
+	tmps := make([]string, 0, len(files))
+	defer func() {
+		for _, tmp := range tmps {
+			os.Remove(tmp)
+		}
+	}()
+	for _, f := range files {
+		tmp, err := writeTemp(f.Path, func(w io.Writer) error {
+			_, err := w.Write(f.Data)
+			return err
+		})
+		if err != nil {
+			return &fs.PathError{Op: "write", Path: f.Path, Err: err}
+		}
+		tmps = append(tmps, tmp)
+	}
+	for i, f := range files {
+		if err := place(tmps[i], f.Path, overwrite); err != nil {
+			if !overwrite {
+				for _, done := range files[:i] {
+					os.Remove(done.Path)
+				}
+			}
+			if errors.Is(err, ErrExists) {
+				err = ErrExists
+			}
+			return &fs.PathError{Op: "write", Path: f.Path, Err: err}
+		}
+	}
+	return nil
+}
+
+// writeTemp writes to a new temporary file in the directory of path and
+// returns its name. The file is removed if write fails.
+func writeTemp(path string, write func(w io.Writer) error) (string, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	err = write(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// place puts the temporary file tmp at path, the caller removes tmp if it
+// is still there. Unless overwrite is set ErrExists is returned if path
+// exists.
+func place(tmp, path string, overwrite bool) error {
+	if overwrite {
+		return os.Rename(tmp, path)
+	}
+	// something, e.g. another file in a batch, could have created path
+	// while writing, a link fails instead of replacing it
+	err := os.Link(tmp, path)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s: %w", path, ErrExists)
+	}
+	if err == nil {
+		return nil
+	}
+	// no hard links on this file system, reserve the name instead
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s: %w", path, ErrExists)
+	}
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
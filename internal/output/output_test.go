@@ -0,0 +1,180 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    string
+		data    NameData
+		want    string
+		wantErr bool
+	}{
+		{"default", "", NewNameData("IF Metall", 38, "ACME AB", "5562344639", 25, 4, 0, false), "IF Metall-2504.txt", false},
+		{"default test location", "", NewNameData("IF Metall", 38, "ACME AB", "5562344639", 25, 4, 2, true), "IF Metall-test-2504-0002.txt", false},
+		{"custom", "{{.Code}}_{{.OrgNum}}_{{.Env}}_20{{.Year}}{{.Period}}.txt", NewNameData("IF Metall", 38, "ACME AB", "5562344639", 25, 4, 0, false), "38_5562344639_prod_202504.txt", false},
+		{"unsafe", "{{.Company}}.txt", NewNameData("IF Metall", 38, "A/B: C", "1", 25, 4, 0, false), "A_B_ C.txt", false},
+		{"unknown field", "{{.Foo}}.txt", NameData{}, "", true},
+		{"empty", "{{.Location}}", NameData{}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FileName(tt.tmpl, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FileName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("FileName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "file.txt")
+	write := func(s string) func(w io.Writer) error {
+		return func(w io.Writer) error {
+			_, err := io.WriteString(w, s)
+			return err
+		}
+	}
+
+	if err := WriteFile(path, false, write("first")); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := WriteFile(path, false, write("second")); !errors.Is(err, ErrExists) {
+		t.Fatalf("WriteFile() error = %v, want ErrExists", err)
+	}
+	if err := WriteFile(path, true, write("third")); err != nil {
+		t.Fatalf("WriteFile() overwrite error = %v", err)
+	}
+	if err := WriteFile(path, true, func(w io.Writer) error { return errors.New("boom") }); err == nil {
+		t.Fatal("WriteFile() expected error from write")
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "third" {
+		t.Errorf("file content = %q, want %q", got, "third")
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected only the target file, got %d entries", len(entries))
+	}
+}
+
+// TestWriteFileConcurrent writes the same new file from several
+// goroutines, only one may succeed without overwrite
+func TestWriteFileConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	const n = 8
+	var started sync.WaitGroup
+	started.Add(n)
+	errs := make(chan error, n)
+	for i := range n {
+		go func() {
+			errs <- WriteFile(path, false, func(w io.Writer) error {
+				// every writer has passed the first check before any is done
+				started.Done()
+				started.Wait()
+				_, err := fmt.Fprint(w, i)
+				return err
+			})
+		}()
+	}
+	ok := 0
+	for range n {
+		err := <-errs
+		switch {
+		case err == nil:
+			ok++
+		case !errors.Is(err, ErrExists):
+			t.Errorf("WriteFile() error = %v, want ErrExists", err)
+		}
+	}
+	if ok != 1 {
+		t.Errorf("%d writes succeeded, want 1", ok)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected only the target file, got %d entries", len(entries))
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := []File{
+		{Path: filepath.Join(dir, "a.txt"), Data: []byte("a")},
+		{Path: filepath.Join(dir, "sub", "b.txt"), Data: []byte("b")},
+		{Path: filepath.Join(dir, "c.txt"), Data: []byte("c")},
+	}
+	ls := func() []string {
+		t.Helper()
+		var names []string
+		filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				rel, _ := filepath.Rel(dir, p)
+				names = append(names, filepath.ToSlash(rel))
+			}
+			return err
+		})
+		return names
+	}
+
+	// the last file exists, nothing is written
+	if err := os.WriteFile(files[2].Path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := WriteFiles(files, false)
+	var pe *fs.PathError
+	if !errors.Is(err, ErrExists) || !errors.As(err, &pe) || pe.Path != files[2].Path {
+		t.Fatalf("WriteFiles() error = %v, want ErrExists for %s", err, files[2].Path)
+	}
+	if got := strings.Join(ls(), ","); got != "c.txt" {
+		t.Errorf("files after ErrExists = %s, want only the existing", got)
+	}
+
+	if err := WriteFiles(files, true); err != nil {
+		t.Fatalf("WriteFiles() overwrite error = %v", err)
+	}
+	if got := strings.Join(ls(), ","); got != "a.txt,c.txt,sub/b.txt" {
+		t.Errorf("files = %s", got)
+	}
+	for _, f := range files {
+		if data, err := os.ReadFile(f.Path); err != nil || string(data) != string(f.Data) {
+			t.Errorf("%s = %q, %v", f.Path, data, err)
+		}
+	}
+}
+
+// TestWriteFilesRollback gives two files the same name, as a file name
+// template without the union can, the first is removed again when the
+// second can not be put in place
+func TestWriteFilesRollback(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "2504.txt")
+	err := WriteFiles([]File{{Path: path, Data: []byte("GS")}, {Path: path, Data: []byte("IF Metall")}}, false)
+	if !errors.Is(err, ErrExists) {
+		t.Fatalf("WriteFiles() error = %v, want ErrExists", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("files were left: %v", entries)
+	}
+}
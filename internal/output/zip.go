@@ -1,13 +1,15 @@
 // SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
 //
 // SPDX-License-Identifier: MIT
-package main
+
+package output
 
 import (
 	"archive/zip"
 	"io"
 )
 
+// ZipFile collects several union files in one zip archive
 type ZipFile struct {
 	z *zip.Writer
 }
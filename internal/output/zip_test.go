@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package output
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestZipFile(t *testing.T) {
+	buf := new(bytes.Buffer)
+	zf, err := NewZipFile(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"IF Metall-2504.txt": "S1\r\n", "GS-2504.txt": "S2\r\n"}
+	for _, name := range []string{"IF Metall-2504.txt", "GS-2504.txt"} {
+		if _, err := zf.AddFile(name, strings.NewReader(files[name])); err != nil {
+			t.Fatalf("AddFile(%s) error = %v", name, err)
+		}
+	}
+	if err := zf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 2 {
+		t.Fatalf("got %d files, want 2", len(zr.File))
+	}
+	for _, zfile := range zr.File {
+		r, err := zfile.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := io.ReadAll(r)
+		r.Close()
+		if string(got) != files[zfile.Name] {
+			t.Errorf("%s = %q, want %q", zfile.Name, got, files[zfile.Name])
+		}
+	}
+}
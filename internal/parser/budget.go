@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package parser
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/ledongthuc/pdf"
+)
+
+// overBudget is panicked by the interpreter callback in checkPage, as
+// pdf.Interpret can not be stopped in another way
+type overBudget struct{ err error }
+
+// checkPage returns an ErrLimit error if reading the content of page n
+// would decode more than lim.MaxStream bytes or give more than
+// lim.MaxTexts text fragments. Page.Content can not be interrupted, so
+// this is what bounds the time and memory spent on a single page.
+//
+// The decoded bytes are the content streams plus the ToUnicode cmap of
+// the font, for every time a font is selected, as the pdf library parses
+// the cmap again each time. The text fragments are counted as the bytes
+// of the shown strings, which is at least the number of characters.
+func checkPage(n int, p pdf.Page, lim Limits) (err error) {
+	if lim.MaxStream <= 0 && lim.MaxTexts <= 0 {
+		return nil
+	}
+	strm := p.V.Key("Contents")
+	streams := []pdf.Value{strm}
+	if strm.Kind() == pdf.Array {
+		streams = streams[:0]
+		for i := 0; i < strm.Len(); i++ {
+			streams = append(streams, strm.Index(i))
+		}
+	}
+
+	var decoded int64
+	overStream := func() error {
+		return fmt.Errorf("%w: sidan %d har mer än %d byte innehåll", ErrLimit, n, lim.MaxStream)
+	}
+	if lim.MaxStream > 0 {
+		for _, s := range streams {
+			size, err := streamSize(s, lim.MaxStream-decoded)
+			if err != nil {
+				return broken(n, err)
+			}
+			decoded += size
+			if decoded > lim.MaxStream {
+				return overStream()
+			}
+		}
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			ob, ok := v.(overBudget)
+			if !ok {
+				panic(v)
+			}
+			err = ob.err
+		}
+	}()
+	cmaps := map[string]int64{}
+	texts := 0
+	pdf.Interpret(strm, func(stk *pdf.Stack, op string) {
+		args := make([]pdf.Value, stk.Len())
+		for i := len(args) - 1; i >= 0; i-- {
+			args[i] = stk.Pop()
+		}
+		switch op {
+		case "Tf":
+			if lim.MaxStream <= 0 || len(args) != 2 {
+				return
+			}
+			name := args[0].Name()
+			size, ok := cmaps[name]
+			if !ok {
+				var err error
+				size, err = streamSize(p.Font(name).V.Key("ToUnicode"), lim.MaxStream-decoded)
+				if err != nil {
+					panic(overBudget{broken(n, err)})
+				}
+				cmaps[name] = size
+			}
+			decoded += size
+			if decoded > lim.MaxStream {
+				panic(overBudget{overStream()})
+			}
+		case "Tj", "'", `"`:
+			if len(args) > 0 {
+				texts += len(args[len(args)-1].RawString())
+			}
+		case "TJ":
+			if len(args) == 1 {
+				for i := 0; i < args[0].Len(); i++ {
+					texts += len(args[0].Index(i).RawString())
+				}
+			}
+		}
+		if lim.MaxTexts > 0 && texts > lim.MaxTexts {
+			panic(overBudget{fmt.Errorf("%w: sidan %d har mer än %d texter", ErrLimit, n, lim.MaxTexts)})
+		}
+	})
+	return nil
+}
+
+// broken is the error for a stream on page n that can not be decoded
+func broken(n int, err error) error {
+	return &spec.ParseError{Page: n, Err: fmt.Errorf("pdf:en är trasig: %w", err)}
+}
+
+// streamSize returns the decoded size of the stream s, but reads no more
+// than max+1 bytes. A value that is not a stream has size 0.
+func streamSize(s pdf.Value, max int64) (int64, error) {
+	if s.Kind() != pdf.Stream {
+		return 0, nil
+	}
+	rc := s.Reader()
+	defer rc.Close()
+	return io.Copy(io.Discard, io.LimitReader(rc, max+1))
+}
@@ -7,6 +7,8 @@ package parser
 import (
 	"fmt"
 	"io"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
 )
@@ -15,7 +17,10 @@ type Document struct {
 	Pages       []*Page
 	CompanyName string
 	CompanyNum  int
-	mu          sync.Mutex
+	// Template is the layout used by Tables, if nil the best
+	// matching built in template is used
+	Template *Template
+	mu       sync.Mutex
 }
 
 func (d *Document) CreatePage() *Page {
@@ -39,38 +44,151 @@ func (d *Document) Fprint(w io.Writer) {
 	}
 }
 
+// TableRow is a row in a union table and where it was found
+type TableRow struct {
+	Page  int // 1 based page number
+	Y     float64
+	Line  int // 1 based line in a csv file, 0 for a pdf
+	Cells []string
+}
+
+// GetTables returns the cells of the rows per union
 func (d *Document) GetTables() map[string][][]string {
 	tables := map[string][][]string{}
-	var table [][]string
-	var tablename string
-	var start, end int
-	for _, p := range d.Pages {
-		data := p.Strings()
-		for i, r := range data {
-			if start == 0 || i < start {
-				for _, s := range r {
-					if strings.HasPrefix(s, "Fackförbund: ") {
-						if tablename != "" {
-							tables[tablename] = table
-						}
+	for k, rows := range d.Tables() {
+		table := make([][]string, len(rows))
+		for i, r := range rows {
+			table[i] = r.Cells
+		}
+		tables[k] = table
+	}
+	return tables
+}
+
+var (
+	rePageNumber = regexp.MustCompile(`(?i)^sida\s+\d+(\s+(av|/)\s+\d+)?$`)
+	// dates, times and page numbers, a line with only these is a page header or footer
+	reStamp = regexp.MustCompile(`(?i)^(\d{4}-\d{2}-\d{2}|\d{2}:\d{2}(:\d{2})?|\d{4}-\d{2}-\d{2} \d{2}:\d{2}(:\d{2})?|sida\s+\d+(\s+(av|/)\s+\d+)?)$`)
+)
 
-						tablename = strings.Trim(strings.Split(s, ":")[1], " \t")
-						table = [][]string{}
-						start = i + 2
-						end = 0
-					}
+// Tables returns the rows per union together with their position.
+//
+// A table starts at the anchor line of the template, followed by the column
+// headers, and ends at the end row. A table that is not ended continues on
+// the next page, whether or not the anchor is repeated there. Repeated
+// column headers, page headers and page footers are not part of any table.
+// The cells of the rows are in the order of the Field constants.
+func (d *Document) Tables() map[string][]TableRow {
+	t := d.template()
+	tables := map[string][]TableRow{}
+	margins := d.margins()
+	var tablename string
+	var headers [][]string
+	var order []int
+	inTable := false
+	for pi, p := range d.Pages {
+		lines := p.Lines()
+		// a line repeated at the same place on another page is only a
+		// margin above the table or below its last row on this page, a
+		// table row can repeat too
+		started := false
+		lastRow := -1
+		rowOrder := order
+		if rowOrder == nil {
+			rowOrder = t.order(nil)
+		}
+		for i := len(lines) - 1; i >= 0; i-- {
+			if _, ok := t.row(lines[i].Cells, rowOrder); ok {
+				lastRow = i
+				break
+			}
+		}
+		for i, l := range lines {
+			r := l.Cells
+			if name, ok := t.unionName(r); ok {
+				started = true
+				tablename = name
+				if _, ok := tables[tablename]; !ok {
+					tables[tablename] = []TableRow{}
 				}
+				headers = nil
+				order = t.order(nil)
+				inTable = true
+				continue
+			}
+			if !inTable || len(r) == 0 {
+				continue
 			}
-			if (start > 0 && i >= start) && (end == 0 || i > end) {
-				if len(r) == 4 {
-					table = append(table, r)
+			if len(headers) < t.HeaderRows {
+				if len(headers) == 0 {
+					order = t.order(r)
 				}
-				end = i
+				headers = append(headers, r)
+				started = true
+				continue
+			}
+			if slices.ContainsFunc(headers, func(h []string) bool { return slices.Equal(r, h) }) {
+				// repeated on a continuation page
+				started = true
+				continue
+			}
+			if margins[marginKey{l.Y, strings.Join(r, ";")}] && (!started || i > lastRow) || isStamp(r) {
+				// page header or footer
+				continue
+			}
+			if t.isSkip(r) {
+				continue
+			}
+			if t.isEnd(r) {
+				inTable = false
+				continue
+			}
+			if cells, ok := t.row(r, order); ok {
+				tables[tablename] = append(tables[tablename], TableRow{Page: pi + 1, Y: l.Y, Cells: cells})
 			}
 		}
 	}
-	if tablename != "" {
-		tables[tablename] = table
-	}
 	return tables
 }
+
+// isStamp reports if the line is a page number or only dates and times
+func isStamp(cells []string) bool {
+	all := true
+	for _, c := range cells {
+		c = strings.TrimSpace(c)
+		if rePageNumber.MatchString(c) {
+			return true
+		}
+		all = all && reStamp.MatchString(c)
+	}
+	return all
+}
+
+type marginKey struct {
+	y    float64
+	text string
+}
+
+// margins returns the lines that are repeated at the same position on
+// more than one page. They are page headers and footers unless they are
+// among the rows of a table, see Tables.
+func (d *Document) margins() map[marginKey]bool {
+	count := map[marginKey]int{}
+	for _, p := range d.Pages {
+		seen := map[marginKey]bool{}
+		for _, l := range p.Lines() {
+			k := marginKey{l.Y, strings.Join(l.Cells, ";")}
+			if !seen[k] {
+				seen[k] = true
+				count[k]++
+			}
+		}
+	}
+	margins := map[marginKey]bool{}
+	for k, n := range count {
+		if n > 1 {
+			margins[k] = true
+		}
+	}
+	return margins
+}
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// DumpVersion is the version of the format written by Save
+const DumpVersion = 1
+
+type dump struct {
+	Version int        `json:"version"`
+	Pages   []dumpPage `json:"pages"`
+}
+
+type dumpPage struct {
+	Width  float64    `json:"width"`
+	Height float64    `json:"height"`
+	Texts  []Fragment `json:"texts"`
+}
+
+func (f Fragment) text() pdf.Text {
+	return pdf.Text{Font: f.Font, FontSize: f.Size, X: f.X, Y: f.Y, W: f.W, S: f.S}
+}
+
+// Save writes the text layer of the document as json. Load reads it back
+// into a Document that parses exactly like the pdf it came from.
+func (d *Document) Save(w io.Writer) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := dump{Version: DumpVersion, Pages: make([]dumpPage, len(d.Pages))}
+	for i, p := range d.Pages {
+		dp := dumpPage{Width: p.Width, Height: p.Height, Texts: make([]Fragment, len(p.Texts))}
+		for j, t := range p.Texts {
+			dp.Texts[j] = fragment(t)
+		}
+		out.Pages[i] = dp
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	return enc.Encode(out)
+}
+
+// Load builds a Document from json written by Save
+func Load(r io.Reader) (*Document, error) {
+	var in dump
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, err
+	}
+	if in.Version != DumpVersion {
+		return nil, fmt.Errorf("unsupported document version %d", in.Version)
+	}
+	doc := &Document{}
+	for _, dp := range in.Pages {
+		texts := make([]pdf.Text, len(dp.Texts))
+		for i, f := range dp.Texts {
+			texts[i] = f.text()
+		}
+		doc.AddPage(NewPage(dp.Width, dp.Height, texts))
+	}
+	return doc, nil
+}
+
+// LoadFile reads a document saved with Save
+func LoadFile(path string) (*Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package parser
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update .golden files in testdata/fixtures")
+
+func TestSaveLoad(t *testing.T) {
+	doc := &Document{}
+	doc.AddPage(testPage(
+		[]string{"Fackförbund: IF Metall"},
+		[]string{"Nr", "Namn", "Personnr", "Avgift"},
+		[]string{"1", "Allan Karlsson", "123456-7890", "570,35"},
+		[]string{"Summa", "570,35"},
+	))
+	// rows as NewPage builds them, testPage sets them directly
+	p := doc.Pages[0]
+	doc.Pages[0] = NewPage(p.Width, p.Height, p.Texts)
+
+	var buf bytes.Buffer
+	if err := doc.Save(&buf); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	got, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(got.Pages) != 1 {
+		t.Fatalf("Load() got %d pages, want 1", len(got.Pages))
+	}
+	if !reflect.DeepEqual(got.Pages[0], doc.Pages[0]) {
+		t.Errorf("Load() page = %+v, want %+v", got.Pages[0], doc.Pages[0])
+	}
+	if !reflect.DeepEqual(got.Tables(), doc.Tables()) {
+		t.Errorf("Load() tables = %v, want %v", got.Tables(), doc.Tables())
+	}
+
+	if _, err := Load(strings.NewReader(`{"version": 99}`)); err == nil {
+		t.Error("Load() of unknown version should fail")
+	}
+}
+
+// TestFixtures replays every saved document in testdata/fixtures and
+// compares the tables with the .golden file next to it.
+// Run with -update after adding a fixture.
+func TestFixtures(t *testing.T) {
+	files, err := filepath.Glob("../../testdata/fixtures/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no fixtures found")
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			doc, err := LoadFile(file)
+			if err != nil {
+				t.Fatalf("LoadFile() error = %v", err)
+			}
+			got := tablesText(doc)
+			golden := strings.TrimSuffix(file, ".json") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("missing golden file, run go test -update: %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("tables differ from %s\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+// tablesText formats the tables like the dump command, with page and y
+func tablesText(doc *Document) string {
+	tables := doc.Tables()
+	var b strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(tables)) {
+		for _, r := range tables[k] {
+			fmt.Fprintf(&b, "%s;%d;%.2f;%s\n", k, r.Page, r.Y, strings.Join(r.Cells, ";"))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package parser
+
+import (
+	"github.com/ledongthuc/pdf"
+)
+
+// Fragment is a piece of text and where it is on the page
+type Fragment struct {
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	W    float64 `json:"w"`
+	Font string  `json:"font"`
+	Size float64 `json:"size"`
+	S    string  `json:"s"`
+}
+
+func fragment(t pdf.Text) Fragment {
+	return Fragment{X: t.X, Y: t.Y, W: t.W, Font: t.Font, Size: t.FontSize, S: t.S}
+}
+
+// RowLayout is a detected row with its merged cells
+type RowLayout struct {
+	Y     float64    `json:"y"`
+	Cells []Fragment `json:"cells"`
+	// Table is the union the row was read as a member of, if any
+	Table string `json:"table,omitempty"`
+}
+
+// PageLayout is everything the parser saw and made of a page
+type PageLayout struct {
+	Page      int         `json:"page"` // 1 based
+	Width     float64     `json:"width"`
+	Height    float64     `json:"height"`
+	Fragments []Fragment  `json:"fragments"`
+	Rows      []RowLayout `json:"rows"`
+}
+
+// Layout returns the fragments, rows and table rows of every page,
+// top to bottom, for finding out why a pdf is not parsed as expected.
+func (d *Document) Layout() []PageLayout {
+	type key struct {
+		page int
+		y    float64
+	}
+	inTable := map[key]string{}
+	for name, rows := range d.Tables() {
+		for _, r := range rows {
+			inTable[key{r.Page, r.Y}] = name
+		}
+	}
+
+	pages := make([]PageLayout, len(d.Pages))
+	for i, p := range d.Pages {
+		pl := PageLayout{
+			Page:      i + 1,
+			Width:     p.Width,
+			Height:    p.Height,
+			Fragments: make([]Fragment, 0, len(p.Texts)),
+			Rows:      []RowLayout{},
+		}
+		for _, t := range p.Texts {
+			pl.Fragments = append(pl.Fragments, fragment(t))
+		}
+		for _, l := range p.Lines() {
+			row := p.Rows[l.Y]
+			rl := RowLayout{Y: l.Y, Cells: make([]Fragment, len(row.Cols)), Table: inTable[key{i + 1, l.Y}]}
+			for j, c := range row.Cols {
+				rl.Cells[j] = fragment(c)
+			}
+			pl.Rows = append(pl.Rows, rl)
+		}
+		pages[i] = pl
+	}
+	return pages
+}
@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package parser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// testPage builds a page with one union table from rows of cells,
+// the first row at the top of the page
+func testPage(rows ...[]string) *Page {
+	p := &Page{Rows: Rows{}, Width: 595, Height: 842}
+	for i, cells := range rows {
+		y := 800 - float64(i)*12
+		var r Row
+		for j, s := range cells {
+			t := pdf.Text{Font: "Helvetica", FontSize: 9, X: 40 + float64(j)*120, Y: y, W: float64(len(s)) * 5, S: s}
+			p.Texts = append(p.Texts, t)
+			r.Cols = append(r.Cols, t)
+		}
+		p.Rows[y] = r
+	}
+	return p
+}
+
+func TestLayout(t *testing.T) {
+	doc := &Document{}
+	doc.AddPage(testPage(
+		[]string{"Fackförbund: IF Metall"},
+		[]string{"Nr", "Namn", "Personnr", "Avgift"},
+		[]string{"1", "Allan Karlsson", "123456-7890", "570,35"},
+		[]string{"Summa", "570,35"},
+	))
+
+	pages := doc.Layout()
+	if len(pages) != 1 {
+		t.Fatalf("Layout() got %d pages, want 1", len(pages))
+	}
+	pl := pages[0]
+	if pl.Page != 1 || pl.Width != 595 || pl.Height != 842 {
+		t.Errorf("Layout() page = %d %vx%v, want 1 595x842", pl.Page, pl.Width, pl.Height)
+	}
+	if len(pl.Fragments) != 11 {
+		t.Errorf("Layout() got %d fragments, want 11", len(pl.Fragments))
+	}
+	if len(pl.Rows) != 4 {
+		t.Fatalf("Layout() got %d rows, want 4", len(pl.Rows))
+	}
+	for i, want := range []string{"", "", "IF Metall", ""} {
+		if pl.Rows[i].Table != want {
+			t.Errorf("row %d table = %q, want %q", i, pl.Rows[i].Table, want)
+		}
+	}
+	if c := pl.Rows[2].Cells[1]; c.S != "Allan Karlsson" || c.X != 160 || c.Size != 9 {
+		t.Errorf("row 2 cell 1 = %+v", c)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSVG(&buf, pl); err != nil {
+		t.Fatalf("WriteSVG() error = %v", err)
+	}
+	// must be well formed xml
+	dec := xml.NewDecoder(bytes.NewReader(buf.Bytes()))
+	for {
+		_, err := dec.Token()
+		if err != nil {
+			if err != io.EOF {
+				t.Fatalf("WriteSVG() invalid xml: %v", err)
+			}
+			break
+		}
+	}
+	for _, want := range []string{"<title>Sida 1</title>", ">Allan Karlsson</text>", "<title>IF Metall</title>", `stroke="red"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("WriteSVG() missing %q", want)
+		}
+	}
+}
@@ -9,25 +9,45 @@ import (
 	"io"
 	"sort"
 	"strings"
+
+	"github.com/ledongthuc/pdf"
 )
 
 type Page struct {
 	Rows Rows
+	// Texts are the fragments in the order they were read
+	Texts []pdf.Text
+	// Width and Height from the MediaBox, in points
+	Width, Height float64
 }
 
-func (p *Page) Strings() [][]string {
+// Line is a row of text on a page at position Y
+type Line struct {
+	Y     float64
+	Cells []string
+}
 
+// Lines returns the rows top to bottom
+func (p *Page) Lines() []Line {
 	keys := make([]float64, 0, len(p.Rows))
 	for k := range p.Rows {
 		keys = append(keys, k)
 	}
-	data := make([][]string, len(keys))
+	lines := make([]Line, len(keys))
 
 	sort.Sort(sort.Reverse(sort.Float64Slice(keys)))
 	for i, k := range keys {
 		row := p.Rows[k]
-		data[i] = row.Strings()
-		// fmt.Printf("[%d] %s\n", i, strings.Join(row.Strings(), ";"))
+		lines[i] = Line{Y: k, Cells: row.Strings()}
+	}
+	return lines
+}
+
+func (p *Page) Strings() [][]string {
+	lines := p.Lines()
+	data := make([][]string, len(lines))
+	for i, l := range lines {
+		data[i] = l.Cells
 	}
 	return data
 }
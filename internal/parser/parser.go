@@ -5,38 +5,145 @@
 package parser
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"time"
 
+	"github.com/kmpm/unionfees/public/spec"
 	"github.com/ledongthuc/pdf"
 )
 
 type Cols []pdf.Text
 
-func ReadPdf(path string) (*Document, error) {
-	doc := Document{}
-	f, r, err := pdf.Open(path)
-	// remember close file
+// Limits bound the work done reading a pdf, so that a corrupt or hostile
+// file can not tie up the program. Zero values mean no limit.
+type Limits struct {
+	MaxSize  int64 // bytes
+	MaxPages int
+	Timeout  time.Duration
+	// MaxStream is the decoded bytes of content and font cmaps on a page
+	MaxStream int64
+	// MaxTexts is the text fragments on a page
+	MaxTexts int
+}
+
+// DefaultLimits are what the server uses unless changed with flags.
+// Real reports are a few pages and parse in well under a second.
+var DefaultLimits = Limits{
+	MaxSize:   20 << 20,
+	MaxPages:  500,
+	Timeout:   30 * time.Second,
+	MaxStream: 16 << 20,
+	MaxTexts:  200000,
+}
+
+// ErrLimit is returned, wrapped, when a pdf exceeds a limit
+var ErrLimit = errors.New("pdf:en är för stor att tolka")
+
+// ReadPdf reads the pdf file at path
+func ReadPdf(ctx context.Context, path string, lim Limits) (*Document, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return &doc, err
+		return &Document{}, err
 	}
-	defer func() {
+	closeFile := func() {
 		err := f.Close()
 		if err != nil {
 			slog.Error("error closing file", "filename", f.Name(), "error", err)
 		} else {
 			slog.Debug("file closed", "filename", f.Name())
 		}
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		closeFile()
+		return &Document{}, err
+	}
+	// the file is read until parsing has stopped, which may be after
+	// parse has returned
+	return parse(ctx, f, fi.Size(), lim, closeFile)
+}
+
+// Read reads a pdf of size bytes from r, e.g. a pdf piped to stdin.
+// Parsing stops when ctx is done or a limit is reached, and a panic in
+// the pdf library is returned as a *spec.ParseError.
+//
+// After a timeout or cancellation Read returns at once. The pdf library
+// can not be interrupted within a page, so the page being parsed is
+// bounded by MaxStream and MaxTexts and r must stay readable until it is
+// done. Use the worker package to stop parsing entirely.
+func Read(ctx context.Context, r io.ReaderAt, size int64, lim Limits) (*Document, error) {
+	return parse(ctx, r, size, lim, nil)
+}
+
+// parse is Read, release is called when r is no longer used
+func parse(ctx context.Context, r io.ReaderAt, size int64, lim Limits, release func()) (*Document, error) {
+	if release == nil {
+		release = func() {}
+	}
+	if lim.MaxSize > 0 && size > lim.MaxSize {
+		release()
+		return &Document{}, fmt.Errorf("%w: %d byte, max %d", ErrLimit, size, lim.MaxSize)
+	}
+	if lim.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, lim.Timeout,
+			fmt.Errorf("%w: tolkningen tog mer än %s", ErrLimit, lim.Timeout))
+		defer cancel()
+	}
+	type result struct {
+		doc *Document
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer release()
+		doc, err := read(ctx, r, size, lim)
+		done <- result{doc, err}
 	}()
-	totalPage := r.NumPage()
+	select {
+	case res := <-done:
+		return res.doc, res.err
+	case <-ctx.Done():
+		return &Document{}, context.Cause(ctx)
+	}
+}
 
-	for pageIndex := 1; pageIndex <= totalPage; pageIndex++ {
-		page := doc.CreatePage()
+func read(ctx context.Context, ra io.ReaderAt, size int64, lim Limits) (doc *Document, err error) {
+	pageIndex := 0
+	defer func() {
+		if v := recover(); v != nil {
+			slog.Error("panic reading pdf", "page", pageIndex, "panic", v)
+			doc, err = &Document{}, &spec.ParseError{Page: pageIndex, Err: fmt.Errorf("pdf:en är trasig: %v", v)}
+		}
+	}()
+	r, err := pdf.NewReader(ra, size)
+	if err != nil {
+		return &Document{}, &spec.ParseError{Err: fmt.Errorf("ogiltig pdf: %w", err)}
+	}
+	doc = &Document{}
+	totalPage := r.NumPage()
+	if lim.MaxPages > 0 && totalPage > lim.MaxPages {
+		return doc, fmt.Errorf("%w: %d sidor, max %d", ErrLimit, totalPage, lim.MaxPages)
+	}
 
+	for pageIndex = 1; pageIndex <= totalPage; pageIndex++ {
+		if ctx.Err() != nil {
+			return doc, context.Cause(ctx)
+		}
 		p := r.Page(pageIndex)
 		if p.V.IsNull() {
+			doc.CreatePage()
 			continue
 		}
-		var lastTextStyle pdf.Text
+		width, height := pageSize(p)
+		if err := checkPage(pageIndex, p, lim); err != nil {
+			return doc, err
+		}
 		content := p.Content()
 
 		// rects := content.Rect
@@ -44,26 +151,51 @@ func ReadPdf(path string) (*Document, error) {
 		// 	fmt.Printf("Rect: %+v\n", r)
 		// }
 
-		texts := content.Text
-		var row Row
-		rows := Rows{0: row}
-
-		for _, text := range texts {
-			if r, ok := rows[text.Y]; ok {
-				// switch if not belonging to current
-				if !row.BelongsToRow(text) {
-					rows[lastTextStyle.Y] = row //store before switching
-					row = r
-				}
-			} else {
+		doc.AddPage(NewPage(width, height, content.Text))
+	}
+
+	return doc, nil
+}
+
+// NewPage groups the text fragments of a page into rows
+func NewPage(width, height float64, texts []pdf.Text) *Page {
+	var lastTextStyle pdf.Text
+	var row Row
+	rows := Rows{}
+
+	for _, text := range texts {
+		if r, ok := rows[text.Y]; ok {
+			// switch if not belonging to current
+			if !row.BelongsToRow(text) {
+				rows[lastTextStyle.Y] = row //store before switching
+				row = r
+			}
+		} else {
+			if row.Cols != nil {
 				rows[lastTextStyle.Y] = row
-				row = Row{}
-				rows[text.Y] = row
 			}
-			lastTextStyle = row.Add(text)
+			row = Row{}
+			rows[text.Y] = row
 		}
-		page.Rows = rows
+		lastTextStyle = row.Add(text)
+	}
+	if row.Cols != nil {
+		// the last row on the page
+		rows[lastTextStyle.Y] = row
 	}
+	return &Page{Rows: rows, Texts: texts, Width: width, Height: height}
+}
 
-	return &doc, nil
+// pageSize returns width and height from the MediaBox, which may be
+// inherited from a parent, A4 if it is missing
+func pageSize(p pdf.Page) (float64, float64) {
+	var box pdf.Value
+	// a broken file may have a loop of parents
+	for i, v := 0, p.V; i < 32 && !v.IsNull() && box.IsNull(); i, v = i+1, v.Key("Parent") {
+		box = v.Key("MediaBox")
+	}
+	if box.Len() != 4 {
+		return 595, 842
+	}
+	return box.Index(2).Float64() - box.Index(0).Float64(), box.Index(3).Float64() - box.Index(1).Float64()
 }
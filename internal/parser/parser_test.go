@@ -0,0 +1,288 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kmpm/unionfees/internal/pdftest"
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/shopspring/decimal"
+)
+
+func testReport(rowsPerPage int, members ...int) pdftest.Report {
+	r := pdftest.Report{
+		Company:     "Exempel AB",
+		OrgNum:      "556677-8899",
+		Period:      "2025-04",
+		RowsPerPage: rowsPerPage,
+	}
+	names := []string{"Anna Andersson", "Bertil (Berra) Bengtsson", "Åsa Öberg",
+		"Maximilian Karl-Gustaf von Lindenberg-Åkerström"}
+	emp := 1
+	for i, n := range members {
+		u := pdftest.Union{Name: []string{"IF Metall", "GS", "Byggnads"}[i]}
+		for j := 0; j < n; j++ {
+			pnr := fmt.Sprintf("%02d0101-%04d", 50+j%50, emp)
+			if j%3 == 1 {
+				pnr = "19" + pnr
+			}
+			u.Members = append(u.Members, pdftest.Member{
+				EmpNo:     fmt.Sprint(emp),
+				Name:      names[j%len(names)],
+				PersonNum: pnr,
+				Amount:    decimal.NewFromInt(int64(250 + emp*13)).Div(decimal.NewFromInt(4)),
+			})
+			emp++
+		}
+		r.Unions = append(r.Unions, u)
+	}
+	return r
+}
+
+func TestReadPdf(t *testing.T) {
+	r := testReport(40, 4, 2)
+	path := filepath.Join(t.TempDir(), "fackavgifter.pdf")
+	if err := pdftest.WriteFile(path, r); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := ReadPdf(context.Background(), path, Limits{})
+	if err != nil {
+		t.Fatalf("ReadPdf() error = %v", err)
+	}
+	if len(doc.Pages) != 2 {
+		t.Fatalf("ReadPdf() got %d pages, want 2", len(doc.Pages))
+	}
+	p := doc.Pages[0]
+	if p.Width < 595 || p.Width > 596 || p.Height < 841 || p.Height > 842 {
+		t.Errorf("page size = %vx%v, want A4", p.Width, p.Height)
+	}
+	lines := p.Strings()
+	if lines[0][0] != "Exempel AB" || lines[1][0] != "556677-8899" {
+		t.Errorf("company lines = %v, %v", lines[0], lines[1])
+	}
+
+	got := doc.GetTables()
+	if want := r.Tables(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetTables() = %v\nwant %v", got, want)
+	}
+	for k, rows := range doc.Tables() {
+		for _, row := range rows {
+			if row.Page < 1 || row.Y <= 0 {
+				t.Errorf("%s: row %v has no position", k, row)
+			}
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	r := testReport(40, 1)
+	data, err := pdftest.Bytes(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := Read(context.Background(), bytes.NewReader(data), int64(len(data)), Limits{})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if got, want := doc.GetTables(), r.Tables(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetTables() = %v\nwant %v", got, want)
+	}
+
+	if _, err := Read(context.Background(), bytes.NewReader([]byte("inte en pdf")), 11, Limits{}); err == nil {
+		t.Error("Read() of garbage should fail")
+	}
+}
+
+func TestReadLimits(t *testing.T) {
+	data, err := pdftest.Bytes(testReport(40, 4, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		data    []byte
+		lim     Limits
+		want    error
+		wantErr string
+	}{
+		{"storlek", context.Background(), data, Limits{MaxSize: 100}, ErrLimit, "byte, max 100"},
+		{"sidor", context.Background(), data, Limits{MaxPages: 1}, ErrLimit, "2 sidor, max 1"},
+		// a hostile file can claim any number of pages
+		{"sidantal i filen", context.Background(), bytes.Replace(data, []byte("/Count 2"), []byte("/Count 9"), 1),
+			Limits{MaxPages: 5}, ErrLimit, "9 sidor, max 5"},
+		{"tid", context.Background(), data, Limits{Timeout: time.Nanosecond}, ErrLimit, "tog mer än 1ns"},
+		{"innehåll", context.Background(), data, Limits{MaxStream: 100}, ErrLimit, "sidan 1 har mer än 100 byte innehåll"},
+		{"texter", context.Background(), data, Limits{MaxTexts: 10}, ErrLimit, "sidan 1 har mer än 10 texter"},
+		{"avbruten", canceled, data, Limits{}, context.Canceled, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(tt.ctx, bytes.NewReader(tt.data), int64(len(tt.data)), tt.lim)
+			if !errors.Is(err, tt.want) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Read() error = %v, want %v %q", err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := Read(context.Background(), bytes.NewReader(data), int64(len(data)), DefaultLimits); err != nil {
+		t.Errorf("Read() with DefaultLimits error = %v", err)
+	}
+}
+
+func TestReadPanic(t *testing.T) {
+	data, err := pdftest.Bytes(testReport(40, 4, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// makes the pdf library panic when the page content is read
+	data = bytes.ReplaceAll(data, []byte("stream"), []byte("streem"))
+	_, err = Read(context.Background(), bytes.NewReader(data), int64(len(data)), Limits{})
+	var pe *spec.ParseError
+	if !errors.As(err, &pe) || pe.Page != 1 {
+		t.Errorf("Read() error = %v, want *spec.ParseError on page 1", err)
+	}
+}
+
+// TestReadConcurrent parses on several goroutines at once,
+// run it with -race
+func TestReadConcurrent(t *testing.T) {
+	r := testReport(10, 25, 8)
+	data, err := pdftest.Bytes(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := r.Tables()
+	errs := make(chan error, 8)
+	for range cap(errs) {
+		go func() {
+			doc, err := Read(context.Background(), bytes.NewReader(data), int64(len(data)), DefaultLimits)
+			if err == nil && !reflect.DeepEqual(doc.GetTables(), want) {
+				err = fmt.Errorf("GetTables() = %v", doc.GetTables())
+			}
+			errs <- err
+		}()
+	}
+	for range cap(errs) {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestReadPdfPageBreak(t *testing.T) {
+	printed := time.Date(2025, 4, 25, 10, 15, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		report pdftest.Report
+		pages  int
+		modify func(r *pdftest.Report)
+	}{
+		{"union repeated", testReport(10, 25), 3, nil},
+		{"only column headers repeated", testReport(10, 25), 3, func(r *pdftest.Report) {
+			r.OmitUnionOnContinuation = true
+		}},
+		{"footer with four cells", testReport(10, 25), 3, func(r *pdftest.Report) {
+			r.Printed = printed
+		}},
+		{"several unions over several pages", testReport(7, 15, 8, 7), 6, func(r *pdftest.Report) {
+			r.OmitUnionOnContinuation = true
+			r.Printed = printed
+		}},
+		{"page break after last row", testReport(10, 20), 2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.report
+			if tt.modify != nil {
+				tt.modify(&r)
+			}
+			data, err := pdftest.Bytes(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := Read(context.Background(), bytes.NewReader(data), int64(len(data)), Limits{})
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if len(doc.Pages) != tt.pages {
+				t.Fatalf("Read() got %d pages, want %d", len(doc.Pages), tt.pages)
+			}
+			got, want := doc.GetTables(), r.Tables()
+			for k := range want {
+				if !reflect.DeepEqual(got[k], want[k]) {
+					t.Errorf("%s got %d rows, want %d\ngot  %v\nwant %v", k, len(got[k]), len(want[k]), got[k], want[k])
+				}
+			}
+			if len(got) != len(want) {
+				t.Errorf("GetTables() got %d unions, want %d", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestTables(t *testing.T) {
+	doc := &Document{}
+	doc.AddPage(testPage(
+		[]string{"Exempel AB"},
+		[]string{"Fackförbund: IF Metall"},
+		[]string{"Nr", "Namn", "Personnr", "Avgift"},
+		[]string{"1", "Allan Karlsson", "123456-7890", "570,35"},
+		[]string{"2025-04-25", "10:15"},
+		[]string{"Summa IF Metall", "570,35"},
+		[]string{"Antal", "1", "Summa", "570,35"},
+		[]string{"Visma Lön", "2025-04-25", "10:15", "Sida 1 av 1"},
+	))
+	got := doc.GetTables()
+	want := map[string][][]string{"IF Metall": {{"1", "Allan Karlsson", "123456-7890", "570,35"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetTables() = %v, want %v", got, want)
+	}
+}
+
+// TestTablesRepeatedRow has the same member at the same place on two
+// pages, it is not a page header
+func TestTablesRepeatedRow(t *testing.T) {
+	row := []string{"1", "Allan Karlsson", "123456-7890", "570,35"}
+	doc := &Document{}
+	doc.AddPage(testPage(
+		[]string{"Exempel AB"},
+		[]string{"Fackförbund: IF Metall"},
+		[]string{"Nr", "Namn", "Personnr", "Avgift"},
+		row,
+		[]string{"2", "Bertil Bengtsson", "790202-2345", "388,50"},
+		[]string{"Summa IF Metall", "958,85"},
+		[]string{"Sida 1 av 2"},
+	))
+	doc.AddPage(testPage(
+		[]string{"Exempel AB"},
+		[]string{"Fackförbund: GS"},
+		[]string{"Nr", "Namn", "Personnr", "Avgift"},
+		row,
+		[]string{"Summa GS", "570,35"},
+		[]string{"Sida 2 av 2"},
+	))
+	got := doc.GetTables()
+	want := map[string][][]string{
+		"IF Metall": {row, {"2", "Bertil Bengtsson", "790202-2345", "388,50"}},
+		"GS":        {row},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetTables() = %v, want %v", got, want)
+	}
+}
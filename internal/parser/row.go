@@ -62,3 +62,24 @@ func isSameColumn(t1, t2 *pdf.Text, n float64) bool {
 	acceptable := t2.X + t2.W + n*w
 	return t1.X <= acceptable
 }
+
+// Runs groups fragments, in the order they were read, into the runs that
+// Row.Add merges into one cell. Line breaks are dropped.
+func Runs(texts []pdf.Text) [][]pdf.Text {
+	var runs [][]pdf.Text
+	var last pdf.Text
+	for _, t := range texts {
+		if t.S == "\n" {
+			continue
+		}
+		if len(runs) > 0 && t.Y == last.Y && isSameColumn(&t, &last, 5) {
+			runs[len(runs)-1] = append(runs[len(runs)-1], t)
+			last.S += t.S
+			last.W = t.X - last.X + t.W
+			continue
+		}
+		runs = append(runs, []pdf.Text{t})
+		last = t
+	}
+	return runs
+}
@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package parser
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+)
+
+// WriteSVG draws a page as the parser sees it. Fragments are drawn as text,
+// detected rows as grey base lines, merged cells as blue boxes, rows read
+// into a union table with a green background and the table columns as red
+// dashed lines.
+func WriteSVG(w io.Writer, pl PageLayout) error {
+	bw := bufio.NewWriter(w)
+	// pdf has y going up, svg has y going down
+	y := func(v float64) float64 { return pl.Height - v }
+
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.2f %.2f" font-family="sans-serif">`+"\n",
+		pl.Width*2, pl.Height*2, pl.Width, pl.Height)
+	fmt.Fprintf(bw, `<title>Sida %d</title>`+"\n", pl.Page)
+	fmt.Fprintf(bw, `<rect width="%.2f" height="%.2f" fill="white"/>`+"\n", pl.Width, pl.Height)
+
+	// table rows and their columns
+	cols := map[string][]float64{}
+	top, bottom := map[string]float64{}, map[string]float64{}
+	for _, r := range pl.Rows {
+		if r.Table == "" {
+			continue
+		}
+		h := rowHeight(r)
+		fmt.Fprintf(bw, `<rect x="0" y="%.2f" width="%.2f" height="%.2f" fill="#c8f0c8"><title>%s</title></rect>`+"\n",
+			y(r.Y)-h, pl.Width, h+2, escape(r.Table))
+		for _, c := range r.Cells {
+			x := math.Round(c.X)
+			if !slices.Contains(cols[r.Table], x) {
+				cols[r.Table] = append(cols[r.Table], x)
+			}
+		}
+		if _, ok := top[r.Table]; !ok || r.Y+h > top[r.Table] {
+			top[r.Table] = r.Y + h
+		}
+		if _, ok := bottom[r.Table]; !ok || r.Y < bottom[r.Table] {
+			bottom[r.Table] = r.Y
+		}
+	}
+	for name, xs := range cols {
+		for _, x := range xs {
+			fmt.Fprintf(bw, `<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="red" stroke-width="0.3" stroke-dasharray="2,1"/>`+"\n",
+				x, y(top[name]), x, y(bottom[name]-2))
+		}
+	}
+
+	// rows and cells
+	for i, r := range pl.Rows {
+		fmt.Fprintf(bw, `<line x1="0" y1="%.2f" x2="%.2f" y2="%.2f" stroke="grey" stroke-width="0.2"/>`+"\n",
+			y(r.Y), pl.Width, y(r.Y))
+		fmt.Fprintf(bw, `<text x="1" y="%.2f" font-size="3" fill="grey">%d</text>`+"\n", y(r.Y)-0.5, i)
+		for _, c := range r.Cells {
+			fmt.Fprintf(bw, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="none" stroke="blue" stroke-width="0.3"><title>%s</title></rect>`+"\n",
+				c.X, y(c.Y)-c.Size, c.W, c.Size, escape(fmt.Sprintf("x=%.2f y=%.2f w=%.2f %s %.1f: %s", c.X, c.Y, c.W, c.Font, c.Size, c.S)))
+		}
+	}
+
+	// fragments
+	for _, f := range pl.Fragments {
+		fmt.Fprintf(bw, `<text x="%.2f" y="%.2f" font-size="%.2f">%s</text>`+"\n", f.X, y(f.Y), f.Size, escape(f.S))
+	}
+	fmt.Fprintln(bw, `</svg>`)
+	return bw.Flush()
+}
+
+// rowHeight is the largest font size in the row
+func rowHeight(r RowLayout) float64 {
+	h := 0.0
+	for _, c := range r.Cells {
+		h = max(h, c.Size)
+	}
+	return h
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
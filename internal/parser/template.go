@@ -0,0 +1,320 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package parser
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Fields a template column can be mapped to. TableRow.Cells are always in
+// this order.
+const (
+	FieldEmpNo     = "empno"
+	FieldName      = "name"
+	FieldPersonNum = "personnum"
+	FieldAmount    = "amount"
+)
+
+var fields = []string{FieldEmpNo, FieldName, FieldPersonNum, FieldAmount}
+
+// Column is a column in a report table
+type Column struct {
+	Header string `toml:"header"` // text in the column header row
+	Field  string `toml:"field"`  // one of the Field constants, empty if not used
+	// Pattern the cell must match for the row to be a member row, optional
+	Pattern string `toml:"pattern"`
+	re      *regexp.Regexp
+}
+
+// TemplateCompany tells where the company is found on the first page
+type TemplateCompany struct {
+	NameKey    string `toml:"name_key"`    // "Key: value" cell, used if found
+	NameLine   int    `toml:"name_line"`   // else first cell on this line, 0 based
+	OrgNumKey  string `toml:"orgnum_key"`  // "Key: value" cell, used if found
+	OrgNumLine int    `toml:"orgnum_line"` // else first cell on this line, 0 based
+}
+
+// Template describes the layout of a report version. A new Visma Lön
+// release or a different report is supported by adding a template.
+type Template struct {
+	Name        string `toml:"name"`
+	Description string `toml:"description"`
+	// Match are patterns that must all match a line on the first page
+	// for the template to be used, the template with most patterns wins
+	Match []string `toml:"match"`
+	// Anchor matches the cell starting a table, group 1 is the union name
+	Anchor string `toml:"anchor"`
+	// HeaderRows is the number of column header rows after the anchor
+	HeaderRows int `toml:"header_rows"`
+	// End matches the first cell of the row ending a table
+	End string `toml:"end"`
+	// Skip matches the first cell of rows inside a table that are not
+	// members, e.g. sub totals. Checked before End.
+	Skip    []string        `toml:"skip"`
+	Columns []Column        `toml:"columns"`
+	Company TemplateCompany `toml:"company"`
+
+	match  []*regexp.Regexp
+	anchor *regexp.Regexp
+	end    *regexp.Regexp
+	skip   []*regexp.Regexp
+}
+
+// DefaultTemplate is used when no template matches
+const DefaultTemplate = "visma-fackavgifter"
+
+//go:embed templates/*.toml
+var templateFS embed.FS
+
+var builtin = mustLoadTemplates(templateFS, "templates")
+
+// Templates returns the built in templates
+func Templates() []*Template {
+	return slices.Clone(builtin)
+}
+
+func mustLoadTemplates(fsys fs.FS, dir string) []*Template {
+	t, err := loadTemplates(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// LoadTemplates reads all *.toml files in dir
+func LoadTemplates(dir string) ([]*Template, error) {
+	return loadTemplates(os.DirFS(dir), ".")
+}
+
+func loadTemplates(fsys fs.FS, dir string) ([]*Template, error) {
+	files, err := fs.Glob(fsys, filepath.ToSlash(filepath.Join(dir, "*.toml")))
+	if err != nil {
+		return nil, err
+	}
+	templates := []*Template{}
+	for _, f := range files {
+		data, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, err
+		}
+		t, err := ParseTemplate(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+// ParseTemplate reads a template in toml
+func ParseTemplate(data []byte) (*Template, error) {
+	t := &Template{}
+	dec := toml.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(t); err != nil {
+		var strict *toml.StrictMissingError
+		if errors.As(err, &strict) {
+			keys := []string{}
+			for _, e := range strict.Errors {
+				keys = append(keys, strings.Join(e.Key(), "."))
+			}
+			return nil, fmt.Errorf("okända nycklar: %s", strings.Join(keys, ", "))
+		}
+		return nil, err
+	}
+	return t, t.compile()
+}
+
+func (t *Template) compile() error {
+	var errs []error
+	re := func(what, s string) *regexp.Regexp {
+		r, err := regexp.Compile(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", what, err))
+		}
+		return r
+	}
+	if t.Name == "" {
+		errs = append(errs, errors.New("name saknas"))
+	}
+	if t.Anchor == "" {
+		errs = append(errs, errors.New("anchor saknas"))
+	}
+	t.anchor = re("anchor", t.Anchor)
+	if t.anchor != nil && t.anchor.NumSubexp() < 1 {
+		errs = append(errs, errors.New("anchor måste ha en grupp för förbundets namn"))
+	}
+	if t.End != "" {
+		t.end = re("end", t.End)
+	}
+	t.match = nil
+	for _, m := range t.Match {
+		t.match = append(t.match, re("match", m))
+	}
+	t.skip = nil
+	for _, s := range t.Skip {
+		t.skip = append(t.skip, re("skip", s))
+	}
+	for i := range t.Columns {
+		c := &t.Columns[i]
+		if c.Field != "" && !slices.Contains(fields, c.Field) {
+			errs = append(errs, fmt.Errorf("kolumn %q: okänt fält %q, använd %s", c.Header, c.Field, strings.Join(fields, ", ")))
+		}
+		if c.Pattern != "" {
+			c.re = re("kolumn "+c.Header, c.Pattern)
+		}
+	}
+	for _, f := range []string{FieldName, FieldPersonNum, FieldAmount} {
+		if !slices.ContainsFunc(t.Columns, func(c Column) bool { return c.Field == f }) {
+			errs = append(errs, fmt.Errorf("kolumn för %s saknas", f))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// score is the number of match patterns, or -1 if not all match the first page
+func (t *Template) score(d *Document) int {
+	if len(d.Pages) == 0 {
+		return -1
+	}
+	lines := d.Pages[0].Lines()
+	for _, m := range t.match {
+		if !slices.ContainsFunc(lines, func(l Line) bool {
+			return slices.ContainsFunc(l.Cells, m.MatchString) || m.MatchString(strings.Join(l.Cells, " "))
+		}) {
+			return -1
+		}
+	}
+	return len(t.match)
+}
+
+// SelectTemplate returns the template among templates that best matches
+// the document, the built in default if none does. A custom template wins
+// a tie with a built in one, so that a copy of a built in template can be
+// changed without changing its match patterns.
+func SelectTemplate(d *Document, templates []*Template) *Template {
+	var best *Template
+	bestScore := -1
+	for _, t := range templates {
+		s := t.score(d)
+		if s > bestScore || s >= 0 && s == bestScore && slices.Contains(builtin, best) && !slices.Contains(builtin, t) {
+			best, bestScore = t, s
+		}
+	}
+	if best == nil {
+		for _, t := range builtin {
+			if t.Name == DefaultTemplate {
+				return t
+			}
+		}
+	}
+	return best
+}
+
+// template returns the template set on the document or selects one
+// among the built in templates
+func (d *Document) template() *Template {
+	if d.Template != nil {
+		return d.Template
+	}
+	return SelectTemplate(d, builtin)
+}
+
+// unionName returns the name if the line starts a union table
+func (t *Template) unionName(cells []string) (string, bool) {
+	for _, s := range cells {
+		if m := t.anchor.FindStringSubmatch(s); m != nil {
+			return strings.TrimSpace(m[1]), true
+		}
+	}
+	return "", false
+}
+
+func (t *Template) isSkip(cells []string) bool {
+	return slices.ContainsFunc(t.skip, func(r *regexp.Regexp) bool { return r.MatchString(cells[0]) })
+}
+
+func (t *Template) isEnd(cells []string) bool {
+	return t.end != nil && t.end.MatchString(cells[0])
+}
+
+// order returns the cell index of each column, found by the column headers
+// so that moved columns are handled. Columns are taken in template order
+// if a header is missing.
+func (t *Template) order(header []string) []int {
+	order := make([]int, len(t.Columns))
+	for i, c := range t.Columns {
+		j := slices.Index(header, c.Header)
+		if j < 0 || len(header) != len(t.Columns) {
+			for i := range order {
+				order[i] = i
+			}
+			return order
+		}
+		order[i] = j
+	}
+	return order
+}
+
+// row maps the cells of a member row to the field order, ok is false if
+// the row is not a member row
+func (t *Template) row(cells []string, order []int) ([]string, bool) {
+	if len(cells) != len(t.Columns) {
+		return nil, false
+	}
+	out := make([]string, len(fields))
+	for i, c := range t.Columns {
+		cell := cells[order[i]]
+		if c.re != nil && !c.re.MatchString(cell) {
+			return nil, false
+		}
+		if j := slices.Index(fields, c.Field); j >= 0 {
+			out[j] = cell
+		}
+	}
+	return out, true
+}
+
+// Company returns the company name and organisationsnummer from the
+// first page as described by the template
+func (d *Document) Company() (name, orgnum string) {
+	if len(d.Pages) == 0 {
+		return "", ""
+	}
+	t := d.template()
+	lines := d.Pages[0].Strings()
+	find := func(key string, line int) string {
+		if key != "" {
+			for _, l := range lines {
+				for i, c := range l {
+					k, v, ok := strings.Cut(c, ":")
+					if !ok || strings.TrimSpace(k) != key {
+						continue
+					}
+					if v = strings.TrimSpace(v); v == "" && i+1 < len(l) {
+						v = strings.TrimSpace(l[i+1])
+					}
+					return v
+				}
+			}
+		}
+		if line < len(lines) && len(lines[line]) > 0 {
+			return lines[line][0]
+		}
+		return ""
+	}
+	return find(t.Company.NameKey, t.Company.NameLine), find(t.Company.OrgNumKey, t.Company.OrgNumLine)
+}
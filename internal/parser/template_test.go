@@ -0,0 +1,202 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package parser
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kmpm/unionfees/internal/pdftest"
+)
+
+func TestSelectTemplate(t *testing.T) {
+	dept := testReport(40, 4, 3)
+	dept.ByDepartment = true
+	for _, u := range dept.Unions {
+		for i := range u.Members {
+			u.Members[i].Department = []string{"Lager", "Montering"}[i*2/len(u.Members)]
+		}
+	}
+
+	tests := []struct {
+		name   string
+		report pdftest.Report
+		want   string
+	}{
+		{"default", testReport(40, 4, 2), "visma-fackavgifter"},
+		{"avdelning", dept, "visma-fackavgifter-avdelning"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := pdftest.Bytes(tt.report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := Read(context.Background(), bytes.NewReader(data), int64(len(data)), Limits{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := SelectTemplate(doc, Templates()); got.Name != tt.want {
+				t.Errorf("SelectTemplate() = %s, want %s", got.Name, tt.want)
+			}
+			if got, want := doc.GetTables(), tt.report.Tables(); !reflect.DeepEqual(got, want) {
+				t.Errorf("GetTables() = %v\nwant %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSelectTemplateCustom(t *testing.T) {
+	data, err := pdftest.Bytes(testReport(40, 4, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := Read(context.Background(), bytes.NewReader(data), int64(len(data)), Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a copy of the default with the same match patterns
+	custom := *SelectTemplate(doc, Templates())
+	custom.Name = "egen"
+	for _, templates := range [][]*Template{
+		append(Templates(), &custom),
+		append([]*Template{&custom}, Templates()...),
+	} {
+		if got := SelectTemplate(doc, templates); got.Name != "egen" {
+			t.Errorf("SelectTemplate() = %s, want egen", got.Name)
+		}
+	}
+}
+
+func TestSelectTemplateFallback(t *testing.T) {
+	doc := &Document{}
+	doc.AddPage(testPage([]string{"Något helt annat"}))
+	if got := SelectTemplate(doc, nil); got == nil || got.Name != DefaultTemplate {
+		t.Errorf("SelectTemplate() = %v, want %s", got, DefaultTemplate)
+	}
+}
+
+func TestTemplateMovedColumns(t *testing.T) {
+	doc := &Document{}
+	doc.AddPage(testPage(
+		[]string{"Fackförbund: IF Metall"},
+		[]string{"Namn", "Anst.nr", "Belopp", "Personnummer"},
+		[]string{"Allan Karlsson", "1", "570,35", "123456-7890"},
+		[]string{"Summa", "570,35"},
+	))
+	want := map[string][][]string{
+		"IF Metall": {{"1", "Allan Karlsson", "123456-7890", "570,35"}},
+	}
+	if got := doc.GetTables(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetTables() = %v, want %v", got, want)
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"ok", `name = "a"
+anchor = '^F: (.+)$'
+[[columns]]
+field = "name"
+[[columns]]
+field = "personnum"
+[[columns]]
+field = "amount"
+`, ""},
+		{"saknar namn", `anchor = '^F: (.+)$'`, "name saknas"},
+		{"saknar grupp", `name = "a"
+anchor = '^F: '`, "anchor måste ha en grupp"},
+		{"okänt fält", `name = "a"
+anchor = '^F: (.+)$'
+[[columns]]
+header = "X"
+field = "salary"`, `okänt fält "salary"`},
+		{"saknar belopp", `name = "a"
+anchor = '^F: (.+)$'
+[[columns]]
+field = "name"
+[[columns]]
+field = "personnum"`, "kolumn för amount saknas"},
+		{"okänd nyckel", `name = "a"
+anker = '^F: (.+)$'`, "anker"},
+		{"felaktigt uttryck", `name = "a"
+anchor = '^F: (.+)$'
+end = '('`, "end:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTemplate([]byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ParseTemplate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseTemplate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	dir := t.TempDir()
+	data, err := templateFS.ReadFile("templates/visma-fackavgifter.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte(`name = "visma-fackavgifter"`), []byte(`name = "egen"`), 1)
+	if err := os.WriteFile(filepath.Join(dir, "egen.toml"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) != 1 || templates[0].Name != "egen" {
+		t.Errorf("LoadTemplates() = %v", templates)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "trasig.toml"), []byte(`name = "x"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTemplates(dir); err == nil || !strings.Contains(err.Error(), "trasig.toml") {
+		t.Errorf("LoadTemplates() error = %v, want file name", err)
+	}
+}
+
+func TestCompany(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     [][]string
+		wantName string
+		wantNum  string
+	}{
+		{"rader", [][]string{{"Exempel AB"}, {"556677-8899"}}, "Exempel AB", "556677-8899"},
+		{"nycklar", [][]string{
+			{"Fackavgifter"},
+			{"Namn: Exempel AB", "Organisationsnr:", "556677-8899"},
+		}, "Exempel AB", "556677-8899"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := &Document{}
+			doc.AddPage(testPage(tt.rows...))
+			name, num := doc.Company()
+			if name != tt.wantName || num != tt.wantNum {
+				t.Errorf("Company() = %q, %q, want %q, %q", name, num, tt.wantName, tt.wantNum)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+// Package pdftest generates pdf files laid out like the Visma Lön report
+// "Fackavgifter", for testing the parser without real payroll data.
+package pdftest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/shopspring/decimal"
+)
+
+// Member is a row in a union table
+type Member struct {
+	EmpNo      string
+	Name       string
+	PersonNum  string
+	Amount     decimal.Decimal
+	Department string // only printed when the report is ByDepartment
+}
+
+// Union is a table of members
+type Union struct {
+	Name    string
+	Members []Member
+}
+
+// Report describes the pdf to generate
+type Report struct {
+	Company string
+	OrgNum  string
+	Period  string // as printed, e.g. "2025-04"
+	Unions  []Union
+	// RowsPerPage is the number of member rows that fit on a page,
+	// a table with more rows continues on the next page. Default 40.
+	RowsPerPage int
+	// OmitUnionOnContinuation leaves out the "Fackförbund:" line on pages
+	// where a table continues, only the column headers are repeated
+	OmitUnionOnContinuation bool
+	// Printed adds a footer with program, print date and time in the
+	// same columns as the table
+	Printed time.Time
+	// ByDepartment adds a department column and a sub total after each
+	// department, members must be sorted by department
+	ByDepartment bool
+}
+
+// Example returns the report the tests share, two unions with a nick
+// name, a person number with century and amounts with and without öre.
+// It is a new value each time so that a test can change it.
+func Example() Report {
+	return Report{
+		Company: "Exempel AB",
+		OrgNum:  "556677-8899",
+		Period:  "2025-04",
+		Unions: []Union{
+			{Name: "IF Metall", Members: []Member{
+				{EmpNo: "1", Name: "Anna Andersson", PersonNum: "850101-1234", Amount: decimal.RequireFromString("412")},
+				{EmpNo: "2", Name: "Bertil (Berra) Bengtsson", PersonNum: "19790202-2345", Amount: decimal.RequireFromString("1388.50")},
+			}},
+			{Name: "GS", Members: []Member{
+				{EmpNo: "3", Name: "Åsa Öberg", PersonNum: "900303-3456", Amount: decimal.RequireFromString("295.25")},
+			}},
+		},
+	}
+}
+
+// Sum is the total for the union as printed on the summary row
+func (u Union) Sum() decimal.Decimal {
+	sum := decimal.Zero
+	for _, m := range u.Members {
+		sum = sum.Add(m.Amount)
+	}
+	return sum
+}
+
+func (u Union) departmentSum(dept string) decimal.Decimal {
+	sum := decimal.Zero
+	for _, m := range u.Members {
+		if m.Department == dept {
+			sum = sum.Add(m.Amount)
+		}
+	}
+	return sum
+}
+
+// Amount formats d as in the report, e.g. 1234,50
+func Amount(d decimal.Decimal) string {
+	return strings.Replace(d.StringFixed(2), ".", ",", 1)
+}
+
+const (
+	top        = 50.0 // first line, from the top of the page
+	lineHeight = 13.0
+	pageHeight = 842.0
+)
+
+// columns of the member table
+var (
+	columns     = []float64{40, 100, 330, 470}
+	deptColumns = []float64{40, 100, 150, 350, 480}
+)
+
+// Write writes the report as a pdf. Every union starts on a new page with
+// the company and report header, a table that does not fit continues on
+// the next page under the same headers, and the last page of a union ends
+// with a summary row. Every page has a footer with the page number.
+func Write(w io.Writer, r Report) error {
+	rowsPerPage := r.RowsPerPage
+	if rowsPerPage <= 0 {
+		rowsPerPage = 40
+	}
+
+	headers := []string{"Anst.nr", "Namn", "Personnummer", "Belopp"}
+	cols := columns
+	cells := func(m Member) []string {
+		return []string{m.EmpNo, m.Name, m.PersonNum, Amount(m.Amount)}
+	}
+	if r.ByDepartment {
+		headers = append([]string{"Avdelning"}, headers...)
+		cols = deptColumns
+		cells = func(m Member) []string {
+			return []string{m.Department, m.EmpNo, m.Name, m.PersonNum, Amount(m.Amount)}
+		}
+	}
+	amountCol := cols[len(cols)-1]
+
+	pdf := fpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetAutoPageBreak(false, 0)
+
+	type page struct {
+		union Union
+		rows  []Member
+		first bool
+		last  bool
+	}
+	var pages []page
+	for _, u := range r.Unions {
+		rows := u.Members
+		for {
+			n := min(rowsPerPage, len(rows))
+			pages = append(pages, page{union: u, rows: rows[:n], first: len(rows) == len(u.Members), last: n == len(rows)})
+			rows = rows[n:]
+			if len(rows) == 0 {
+				break
+			}
+		}
+	}
+
+	for pi, p := range pages {
+		pdf.AddPage()
+		y := top
+		text := func(size, x float64, s string) {
+			pdf.SetFont("Helvetica", "", size)
+			pdf.Text(x, y, tr(s))
+		}
+		text(11, 40, r.Company)
+		y += lineHeight
+		text(9, 40, r.OrgNum)
+		y += lineHeight
+		text(14, 40, "Fackavgifter")
+		text(9, 400, "Period: "+r.Period)
+		y += lineHeight * 2
+
+		if p.first || !r.OmitUnionOnContinuation {
+			text(9, 40, "Fackförbund: "+p.union.Name)
+		}
+		y += lineHeight
+		for i, h := range headers {
+			text(9, cols[i], h)
+		}
+		y += lineHeight
+		for i, m := range p.rows {
+			for i, s := range cells(m) {
+				text(9, cols[i], s)
+			}
+			y += lineHeight
+			if r.ByDepartment && (i+1 == len(p.rows) && p.last || i+1 < len(p.rows) && p.rows[i+1].Department != m.Department) {
+				text(9, 40, "Summa avdelning "+m.Department)
+				text(9, amountCol, Amount(p.union.departmentSum(m.Department)))
+				y += lineHeight
+			}
+		}
+		if p.last {
+			y += lineHeight / 2
+			text(9, 40, "Summa "+p.union.Name)
+			text(9, amountCol, Amount(p.union.Sum()))
+		}
+		y = pageHeight - 30
+		if !r.Printed.IsZero() {
+			text(8, columns[0], "Visma Lön")
+			text(8, columns[1], r.Printed.Format("2006-01-02"))
+			text(8, columns[2], r.Printed.Format("15:04"))
+		}
+		text(8, columns[3], fmt.Sprintf("Sida %d av %d", pi+1, len(pages)))
+	}
+	return pdf.Output(w)
+}
+
+// Bytes returns the report as a pdf
+func Bytes(r Report) ([]byte, error) {
+	var buf bytes.Buffer
+	err := Write(&buf, r)
+	return buf.Bytes(), err
+}
+
+// WriteFile writes the report as a pdf to path
+func WriteFile(path string, r Report) error {
+	data, err := Bytes(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Tables returns the cells a parser should find per union
+func (r Report) Tables() map[string][][]string {
+	tables := map[string][][]string{}
+	for _, u := range r.Unions {
+		rows := [][]string{}
+		for _, m := range u.Members {
+			rows = append(rows, []string{m.EmpNo, m.Name, m.PersonNum, Amount(m.Amount)})
+		}
+		tables[u.Name] = rows
+	}
+	return tables
+}
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package internal
+
+import (
+	"fmt"
+	"time"
+)
+
+// PeriodKind tells how a period relates to the current month.
+type PeriodKind int
+
+const (
+	// PeriodOpen is the current, previous or next month. This is the normal
+	// monthly submission, including December submitted in early January.
+	PeriodOpen PeriodKind = iota
+	// PeriodCorrection is a closed period older than the previous month.
+	PeriodCorrection
+)
+
+func (k PeriodKind) String() string {
+	switch k {
+	case PeriodOpen:
+		return "Ordinarie"
+	case PeriodCorrection:
+		return "Rättelse"
+	default:
+		return "Okänd"
+	}
+}
+
+// PeriodPolicy decides which accounting periods may be submitted.
+type PeriodPolicy struct {
+	// MaxAge is the number of months back from the current month
+	// that a period may be and still be accepted as a correction.
+	MaxAge int
+}
+
+// DefaultPeriodPolicy accepts corrections for a little more than a year back.
+var DefaultPeriodPolicy = PeriodPolicy{MaxAge: 13}
+
+// monthsBetween returns the number of whole months from period/year (YY) to now.
+func monthsBetween(year, period int, now time.Time) int {
+	a := (2000+year)*12 + period - 1
+	b := now.Year()*12 + int(now.Month()) - 1
+	return b - a
+}
+
+// Check validates period (MM) and year (YY) against now and returns
+// what kind of submission it is.
+func (p PeriodPolicy) Check(year, period int, now time.Time) (PeriodKind, error) {
+	if period < 1 || period > 12 {
+		return PeriodOpen, fmt.Errorf("felaktig period/månad: %d", period)
+	}
+	if year < 0 || year > 99 {
+		return PeriodOpen, fmt.Errorf("felaktigt år: %d", year)
+	}
+	age := monthsBetween(year, period, now)
+	switch {
+	case age < -1:
+		return PeriodOpen, fmt.Errorf("period %02d/%02d ligger i framtiden", period, year)
+	case age <= 1:
+		return PeriodOpen, nil
+	case age <= p.MaxAge:
+		return PeriodCorrection, nil
+	default:
+		return PeriodCorrection, fmt.Errorf("period %02d/%02d är äldre än %d månader", period, year, p.MaxAge)
+	}
+}
@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeriodPolicy_Check(t *testing.T) {
+	now := time.Date(2025, 1, 8, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name    string
+		year    int
+		period  int
+		want    PeriodKind
+		wantErr bool
+	}{
+		{"current month", 25, 1, PeriodOpen, false},
+		{"december in january", 24, 12, PeriodOpen, false},
+		{"next month", 25, 2, PeriodOpen, false},
+		{"far future", 25, 3, PeriodOpen, true},
+		{"two months back", 24, 11, PeriodCorrection, false},
+		{"max age", 23, 12, PeriodCorrection, false},
+		{"too old", 23, 11, PeriodCorrection, true},
+		{"bad period", 25, 13, PeriodOpen, true},
+		{"bad year", 100, 1, PeriodOpen, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DefaultPeriodPolicy.Check(tt.year, tt.period, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
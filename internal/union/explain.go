@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package union
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/kmpm/unionfees/public/spec"
+)
+
+// Line is one record as it is written to the file
+type Line struct {
+	No   int // 1 based line number in the file
+	Type string
+	Text string
+	S2   *spec.S2Spec // set for S2 records
+}
+
+// Lines returns the records in the order WriteTable writes them
+func Lines(locs spec.Locations, unionNo UnionCode) []Line {
+	lines := []Line{}
+	add := func(typ string, s2 *spec.S2Spec, fn func(w *writer)) {
+		lines = append(lines, Line{No: len(lines) + 1, Type: typ, Text: record(fn), S2: s2})
+	}
+	for _, locnum := range slices.Sorted(maps.Keys(locs)) {
+		loc := locs[locnum]
+		add("S1", nil, func(w *writer) { w.writeS1(loc.S1, int(unionNo)) })
+		for i := range loc.S2 {
+			s2 := &loc.S2[i]
+			add("S2", s2, func(w *writer) { w.writeS2(*s2, int(unionNo)) })
+		}
+		add("S3", nil, func(w *writer) { w.writeS3(loc.S3, int(unionNo)) })
+	}
+	return lines
+}
+
+// Explain writes every record followed by where it came from
+// and which transformations were applied
+func Explain(w io.Writer, locs spec.Locations, unionNo UnionCode) error {
+	for _, l := range Lines(locs, unionNo) {
+		if _, err := fmt.Fprintf(w, "%4d %s\n", l.No, l.Text); err != nil {
+			return err
+		}
+		var err error
+		switch {
+		case l.Type == "S1":
+			_, err = fmt.Fprintf(w, "     öppningspost för plats %s, från företagsuppgifter och utbetalningsdatum\n", l.Text[4:8])
+		case l.Type == "S3":
+			_, err = fmt.Fprintf(w, "     summering för plats %s, beräknad från posterna ovan\n", l.Text[4:8])
+		case l.S2.Source == nil:
+			_, err = fmt.Fprintln(w, "     källa okänd")
+		default:
+			src := l.S2.Source
+			switch {
+			case src.File != "" && src.Line > 0:
+				_, err = fmt.Fprintf(w, "     källa: %s rad %d\n", src.File, src.Line)
+			case src.File != "":
+				_, err = fmt.Fprintf(w, "     källa: %s sida %d, y=%.2f\n", src.File, src.Page, src.Y)
+			}
+			if err == nil && len(src.Cells) > 0 {
+				_, err = fmt.Fprintf(w, "     celler: %s\n", strings.Join(src.Cells, " | "))
+			}
+			for i, step := range src.Steps {
+				if err == nil {
+					_, err = fmt.Fprintf(w, "     %d. %s\n", i+1, step)
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
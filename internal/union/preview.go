@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package union
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kmpm/unionfees/public/spec"
+)
+
+// Field is a fixed width field in a record.
+// Names are the same as in docs/ifmetall.hexpat.
+type Field struct {
+	Name  string
+	Width int
+	Text  bool // text field, padded with spaces to the right
+}
+
+var (
+	S1Layout = []Field{
+		{"line_type", 2, false}, {"union_no", 2, false}, {"location", 4, false},
+		{"company_code", 10, false}, {"company_name", 24, true}, {"acc_type", 1, false},
+		{"acc_month", 2, false}, {"acc_year", 2, false}, {"date", 6, false}, {"filler", 13, false},
+	}
+	S2Layout = []Field{
+		{"line_type", 2, false}, {"union_no", 2, false}, {"location", 4, false},
+		{"id", 10, false}, {"name", 24, true}, {"fee", 6, false},
+		{"check", 6, false}, {"paycode", 2, false}, {"filler", 10, false},
+	}
+	S3Layout = []Field{
+		{"line_type", 2, false}, {"union_no", 2, false}, {"location", 4, false},
+		{"company_code", 10, false}, {"company_name", 24, true}, {"entry_count", 6, false},
+		{"sum_fee", 9, false}, {"sum_check", 9, false},
+	}
+)
+
+// record renders one record with fn, without line ending
+func record(fn func(w *writer)) string {
+	var sb strings.Builder
+	w := writer{buf: bufio.NewWriter(&sb)}
+	fn(&w)
+	w.buf.Flush()
+	return strings.TrimRight(sb.String(), "\r\n")
+}
+
+// Preview writes the records exactly as WriteTable would, each with a column
+// ruler and the fields marked underneath. Text fields padded with spaces are
+// marked with '.' and names that did not fit are marked with '!'.
+// It returns the number of truncated names.
+func Preview(w io.Writer, locs spec.Locations, unionNo UnionCode) (int, error) {
+	pw := &previewWriter{w: w}
+	for _, locnum := range slices.Sorted(maps.Keys(locs)) {
+		loc := locs[locnum]
+		pw.record("S1", record(func(w *writer) { w.writeS1(loc.S1, int(unionNo)) }), S1Layout, loc.S1.CompanyName)
+		for _, s2 := range loc.S2 {
+			pw.record("S2", record(func(w *writer) { w.writeS2(s2, int(unionNo)) }), S2Layout, s2.Name)
+		}
+		pw.record("S3", record(func(w *writer) { w.writeS3(loc.S3, int(unionNo)) }), S3Layout, loc.S3.CompanyName)
+	}
+	return pw.truncated, pw.err
+}
+
+type previewWriter struct {
+	w         io.Writer
+	n         int
+	truncated int
+	err       error
+}
+
+func (pw *previewWriter) printf(format string, args ...any) {
+	if pw.err != nil {
+		return
+	}
+	_, pw.err = fmt.Fprintf(pw.w, format, args...)
+}
+
+func (pw *previewWriter) record(typ, line string, layout []Field, text string) {
+	pw.n++
+	length := utf8.RuneCountInString(line)
+	var tens, ones strings.Builder
+	for i := 1; i <= length; i++ {
+		if i%10 == 0 {
+			tens.WriteString(fmt.Sprint(i / 10 % 10))
+		} else {
+			tens.WriteByte(' ')
+		}
+		ones.WriteString(fmt.Sprint(i % 10))
+	}
+	pw.printf("%s rad %d\n", typ, pw.n)
+	pw.printf("%s\n%s\n%s\n", tens.String(), ones.String(), line)
+
+	runes := []rune(line)
+	var marks strings.Builder
+	var legend strings.Builder
+	pos := 0
+	for i, f := range layout {
+		letter := rune('a' + i)
+		value := string(runes[pos : pos+f.Width])
+		note := ""
+		used := f.Width
+		if f.Text {
+			used = utf8.RuneCountInString(strings.TrimRight(value, " "))
+			if used < f.Width {
+				note = fmt.Sprintf(" (utfyllt med %d blanksteg)", f.Width-used)
+			}
+			full := strings.ToUpper(strings.TrimSpace(text))
+			if utf8.RuneCountInString(full) > f.Width {
+				note = fmt.Sprintf(" AVKORTAT från %q", full)
+				pw.truncated++
+			}
+		}
+		for j := 0; j < f.Width; j++ {
+			switch {
+			case j >= used:
+				marks.WriteRune('.')
+			case note != "" && strings.HasPrefix(note, " AVKORTAT") && j == f.Width-1:
+				marks.WriteRune('!')
+			default:
+				marks.WriteRune(letter)
+			}
+		}
+		fmt.Fprintf(&legend, "  %c %02d-%02d %-13s %q%s\n", letter, pos+1, pos+f.Width, f.Name, value, note)
+		pos += f.Width
+	}
+	pw.printf("%s\n%s\n", marks.String(), legend.String())
+}
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package union
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/kmpm/unionfees/public/spec"
+)
+
+func TestLayouts(t *testing.T) {
+	for name, layout := range map[string][]Field{"S1": S1Layout, "S2": S2Layout, "S3": S3Layout} {
+		n := 0
+		for _, f := range layout {
+			n += f.Width
+		}
+		if n != lineLength {
+			t.Errorf("%s layout is %d characters, want %d", name, n, lineLength)
+		}
+	}
+}
+
+func TestPreview(t *testing.T) {
+	loc := testLocations[1]
+	loc.S2 = append([]spec.S2Spec{}, loc.S2...)
+	loc.S2[0].Name = "Karlsson-Andersson Allan Bertil"
+
+	var buf bytes.Buffer
+	n, err := Preview(&buf, spec.Locations{1: loc}, CodeIFMetall)
+	if err != nil {
+		t.Fatalf("Preview() error = %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Preview() truncated = %d, want 1", n)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"S23800011234567890KARLSSON-ANDERSSON ALLAN057035000000010000000000",
+		"aabbccccddddddddddeeeeeeeeeeeeeeeeeeeeeee!ffffffgggggghhiiiiiiiiii",
+		"AVKORTAT från \"KARLSSON-ANDERSSON ALLAN BERTIL\"",
+		"e 19-42 name",
+		"(utfyllt med 9 blanksteg)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Preview() missing %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	loc := testLocations[1]
+	loc.S2 = append([]spec.S2Spec{}, loc.S2...)
+	loc.S2[0].Source = &spec.Provenance{
+		File:  "rapport.pdf",
+		Page:  2,
+		Y:     512.5,
+		Cells: []string{"1", "Allan Karlsson", "123456-7890", "570,35"},
+		Steps: []string{"namn omvänt till efternamn förnamn: \"Karlsson Allan\""},
+	}
+
+	lines := Lines(spec.Locations{1: loc}, CodeIFMetall)
+	if len(lines) != 5 {
+		t.Fatalf("Lines() got %d lines, want 5", len(lines))
+	}
+	if lines[1].S2 == nil || lines[1].S2.PersonNum != 1234567890 {
+		t.Errorf("Lines()[1] = %+v, want first S2", lines[1])
+	}
+
+	var buf bytes.Buffer
+	if err := Explain(&buf, spec.Locations{1: loc}, CodeIFMetall); err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"   2 S23800011234567890KARLSSON ALLAN",
+		"källa: rapport.pdf sida 2, y=512.50",
+		"celler: 1 | Allan Karlsson | 123456-7890 | 570,35",
+		"1. namn omvänt",
+		"källa okänd",
+		"summering för plats 0001",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Explain() missing %q in\n%s", want, out)
+		}
+	}
+}
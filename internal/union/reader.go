@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package union
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/shopspring/decimal"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/transform"
+)
+
+// lineLength is the length of every record, excluding CRLF
+const lineLength = 66
+
+type reader struct {
+	line []rune
+	pos  int
+	err  error
+}
+
+func (r *reader) str(n int) string {
+	if r.err != nil {
+		return ""
+	}
+	if r.pos+n > len(r.line) {
+		r.err = fmt.Errorf("record too short at position %d", r.pos)
+		return ""
+	}
+	s := string(r.line[r.pos : r.pos+n])
+	r.pos += n
+	return s
+}
+
+func (r *reader) int(n int) int {
+	s := r.str(n)
+	if r.err != nil {
+		return 0
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		r.err = fmt.Errorf("invalid number %q at position %d: %w", s, r.pos-n, err)
+	}
+	return v
+}
+
+// amount reads i integer digits and d decimal digits
+func (r *reader) amount(i, d int) decimal.Decimal {
+	s := r.str(i + d)
+	if r.err != nil {
+		return decimal.Zero
+	}
+	v, err := decimal.NewFromString(s[:i] + "." + s[i:])
+	if err != nil {
+		r.err = fmt.Errorf("invalid amount %q at position %d: %w", s, r.pos-i-d, err)
+	}
+	return v
+}
+
+func (r *reader) date() time.Time {
+	s := r.str(6)
+	if r.err != nil {
+		return time.Time{}
+	}
+	t, err := time.ParseInLocation("060102", s, time.Local)
+	if err != nil {
+		r.err = fmt.Errorf("invalid date %q: %w", s, err)
+	}
+	return t
+}
+
+// ReadTable reads a union file, as written by WriteTable, back into locations.
+func ReadTable(ir io.Reader) (spec.Locations, UnionCode, error) {
+	return ReadTableEncoding(ir, charmap.Windows1252)
+}
+
+// ReadTableEncoding reads a union file written with the character encoding e
+func ReadTableEncoding(ir io.Reader, e encoding.Encoding) (spec.Locations, UnionCode, error) {
+	locs := spec.Locations{}
+	var unionNo UnionCode
+	var current *spec.Spec
+
+	scanner := bufio.NewScanner(transform.NewReader(ir, e.NewDecoder()))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		r := reader{line: []rune(line)}
+		if len(r.line) != lineLength {
+			return locs, unionNo, fmt.Errorf("line %d: expected %d characters, got %d", lineNo, lineLength, len(r.line))
+		}
+		typ := r.str(2)
+		code := UnionCode(r.int(2))
+		if unionNo == 0 {
+			unionNo = code
+		} else if code != unionNo {
+			return locs, unionNo, fmt.Errorf("line %d: mixed union numbers %d and %d", lineNo, unionNo, code)
+		}
+		locnum := r.int(4)
+
+		switch typ {
+		case "S1":
+			if current != nil {
+				return locs, unionNo, fmt.Errorf("line %d: S1 before S3 of location %d", lineNo, current.S1.LocNum)
+			}
+			current = &spec.Spec{S2: []spec.S2Spec{}}
+			current.S1 = spec.S1Spec{
+				LocNum:         locnum,
+				CompanyNum:     r.int(10),
+				CompanyName:    strings.TrimRight(r.str(24), " "),
+				AccountingType: spec.AccountingType(r.int(1)),
+				Period:         r.int(2),
+				Year:           r.int(2),
+			}
+			current.S1.TransactionDate = r.date()
+		case "S2":
+			if current == nil {
+				return locs, unionNo, fmt.Errorf("line %d: S2 without S1", lineNo)
+			}
+			s2 := spec.S2Spec{
+				LocNum:    locnum,
+				PersonNum: r.int(10),
+				Name:      strings.TrimRight(r.str(24), " "),
+			}
+			s2.Amount = r.amount(4, 2)
+			s2.ControlAmount = r.amount(4, 2)
+			s2.PayCode = spec.PayCode(r.int(2))
+			current.S2 = append(current.S2, s2)
+		case "S3":
+			if current == nil {
+				return locs, unionNo, fmt.Errorf("line %d: S3 without S1", lineNo)
+			}
+			current.S3 = spec.S3Spec{
+				LocNum:      locnum,
+				CompanyNum:  r.int(10),
+				CompanyName: strings.TrimRight(r.str(24), " "),
+				Records:     r.int(6),
+			}
+			current.S3.SumAmout = r.amount(7, 2)
+			current.S3.SumControlAmount = r.amount(7, 2)
+			locs[locnum] = *current
+			current = nil
+		default:
+			return locs, unionNo, fmt.Errorf("line %d: unknown record type %q", lineNo, typ)
+		}
+		if r.err != nil {
+			return locs, unionNo, fmt.Errorf("line %d: %w", lineNo, r.err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return locs, unionNo, err
+	}
+	if current != nil {
+		return locs, unionNo, fmt.Errorf("location %d is missing S3", current.S1.LocNum)
+	}
+	return locs, unionNo, nil
+}
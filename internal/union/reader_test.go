@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package union
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kmpm/unionfees/public/spec"
+)
+
+func TestReadTable(t *testing.T) {
+	f, err := os.Open("../../testdata/sample.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	locs, code, err := ReadTable(f)
+	if err != nil {
+		t.Fatalf("ReadTable() error = %v", err)
+	}
+	if code != CodeIFMetall {
+		t.Errorf("ReadTable() code = %d, want %d", code, CodeIFMetall)
+	}
+	want := testLocations[1]
+	got, ok := locs[1]
+	if !ok {
+		t.Fatalf("ReadTable() missing location 1")
+	}
+	if got.S1.CompanyNum != want.S1.CompanyNum || got.S1.Period != want.S1.Period || got.S1.Year != want.S1.Year {
+		t.Errorf("ReadTable() S1 = %+v, want %+v", got.S1, want.S1)
+	}
+	if !got.S1.TransactionDate.Equal(want.S1.TransactionDate) {
+		t.Errorf("ReadTable() date = %v, want %v", got.S1.TransactionDate, want.S1.TransactionDate)
+	}
+	if len(got.S2) != len(want.S2) {
+		t.Fatalf("ReadTable() got %d S2, want %d", len(got.S2), len(want.S2))
+	}
+	for i, s2 := range got.S2 {
+		w := want.S2[i]
+		if s2.PersonNum != w.PersonNum || !s2.Amount.Equal(w.Amount) || s2.PayCode != w.PayCode {
+			t.Errorf("ReadTable() S2[%d] = %+v, want %+v", i, s2, w)
+		}
+		if s2.Name != strings.ToUpper(w.Name) {
+			t.Errorf("ReadTable() S2[%d].Name = %q, want %q", i, s2.Name, strings.ToUpper(w.Name))
+		}
+	}
+	if got.S3.Records != want.S3.Records || !got.S3.SumAmout.Equal(want.S3.SumAmout) {
+		t.Errorf("ReadTable() S3 = %+v, want %+v", got.S3, want.S3)
+	}
+}
+
+func TestReadTable_Invalid(t *testing.T) {
+	_, _, err := ReadTable(strings.NewReader("S2380001\r\n"))
+	if err == nil {
+		t.Fatal("ReadTable() expected error for short line")
+	}
+}
+
+// TestReadTableEncoding writes a name with a character that the other
+// encoding reads as something else
+func TestReadTableEncoding(t *testing.T) {
+	tests := []struct {
+		enc   string
+		other string
+		name  string
+	}{
+		{"windows-1252", "iso-8859-1", "ŠIMIC"},
+		{"iso-8859-1", "windows-1252", "L\u008aUND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.enc, func(t *testing.T) {
+			e, _ := Encoding(tt.enc)
+			other, _ := Encoding(tt.other)
+			s2 := append([]spec.S2Spec{}, testLocations[1].S2...)
+			s2[0].Name = tt.name
+			locs := spec.Locations{1: {S1: testLocations[1].S1, S2: s2, S3: testLocations[1].S3}}
+			var buf bytes.Buffer
+			if err := WriteTableEncoding(&buf, locs, CodeIFMetall, e); err != nil {
+				t.Fatal(err)
+			}
+
+			got, _, err := ReadTableEncoding(bytes.NewReader(buf.Bytes()), e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name := strings.TrimSpace(got[1].S2[0].Name); name != tt.name {
+				t.Errorf("ReadTableEncoding() name = %q, want %q", name, tt.name)
+			}
+			got, _, err = ReadTableEncoding(bytes.NewReader(buf.Bytes()), other)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name := strings.TrimSpace(got[1].S2[0].Name); name == tt.name {
+				t.Errorf("ReadTableEncoding() with %s name = %q, want it read differently", tt.other, name)
+			}
+		})
+	}
+}
@@ -8,13 +8,15 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"log"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/kmpm/unionfees/public/spec"
 	"github.com/shopspring/decimal"
+	"golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/charmap"
 	"golang.org/x/text/transform"
 )
@@ -37,14 +39,23 @@ func (c UnionCode) String() string {
 	}
 }
 
+// writer writes the fixed width records. The first error is kept and
+// everything after it is skipped. Without check, as when rendering a
+// preview, values are written even if they do not fit.
 type writer struct {
-	buf *bufio.Writer
+	buf      *bufio.Writer
+	enc      encoding.Encoding
+	check    *encoding.Encoder // nil to not check the encoding
+	record   string            // S1, S2 or S3 being written
+	location int
+	row      int // S2 record in the location, 1 based
+	err      error
 }
 
 // padRight trims and pads string to n characters
 func padRight(v string, n int) string {
-	if len(v) > n {
-		v = v[0:n]
+	if r := []rune(v); len(r) > n {
+		v = string(r[:n])
 	}
 	format := fmt.Sprintf("%%-%ds", n)
 	return fmt.Sprintf(format, v)
@@ -55,20 +66,6 @@ func padZero(v int, n int) string {
 	return fmt.Sprintf(format, v)
 }
 
-func padDecimal(d decimal.Decimal, ni, nd int) string {
-	v := strings.Split(d.StringFixed(2), ".")
-	a, err := strconv.Atoi(v[0])
-	if err != nil {
-		log.Fatal(err)
-	}
-	b, err := strconv.Atoi(v[1])
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	return padZero(a, ni) + padZero(b, nd)
-}
-
 func padDate(v time.Time) string {
 	return fmt.Sprintf("%02d%02d%02d",
 		v.Year()-2000,
@@ -77,83 +74,152 @@ func padDate(v time.Time) string {
 	)
 }
 
-func (w *writer) writeStr(s string) error {
-	_, err := w.buf.WriteString(s)
-	if err != nil {
-		log.Fatalf("error writing %s: %v", s, err)
+// fail records err with the location and record being written
+func (w *writer) fail(field string, err error) {
+	if w.err != nil || w.check == nil {
+		return
 	}
-	return err
+	ve := &spec.ValidationError{Location: w.location, Field: field, Msg: err.Error(), Err: err}
+	if w.record == "S2" {
+		ve.Record = w.row
+	}
+	w.err = ve
 }
 
-func (w *writer) writeStrR(s string, n int) error {
-	return w.writeStr(padRight(strings.ToUpper(s), n))
+func (w *writer) writeStr(s string) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = w.buf.WriteString(s)
 }
 
-func (w *writer) writeInt(v, n int) error {
-	return w.writeStr(padZero(v, n))
+// writeStrR writes s in upper case, trimmed and padded to n characters
+func (w *writer) writeStrR(field, s string, n int) {
+	v := padRight(strings.ToUpper(s), n)
+	for _, r := range v {
+		if w.check == nil {
+			break
+		}
+		if _, err := w.check.String(string(r)); err != nil {
+			w.fail(field, &spec.EncodingError{Encoding: fmt.Sprint(w.enc), Rune: r, Value: s})
+			break
+		}
+	}
+	w.writeStr(v)
+}
+
+func (w *writer) writeInt(field string, v, n int) {
+	s := padZero(v, n)
+	if v < 0 || len(s) > n {
+		w.fail(field, &spec.FormatOverflowError{Record: w.record, Field: field, Value: strconv.Itoa(v), Width: n})
+	}
+	w.writeStr(s)
 }
 
 // writeAmount with zero padded i characters for integer part
 // and d characters for decimal part
-func (w *writer) writeAmount(v decimal.Decimal, i, d int) error {
-	return w.writeStr(padDecimal(v, i, d))
+func (w *writer) writeAmount(field string, v decimal.Decimal, i, d int) {
+	s := strings.Replace(v.Abs().StringFixed(int32(d)), ".", "", 1)
+	if len(s) < i+d {
+		s = strings.Repeat("0", i+d-len(s)) + s
+	}
+	if v.IsNegative() || len(s) > i+d {
+		w.fail(field, &spec.FormatOverflowError{Record: w.record, Field: field, Value: v.StringFixed(int32(d)), Width: i + d})
+		if v.IsNegative() {
+			s = "-" + s
+		}
+	}
+	w.writeStr(s)
 }
 
 func (w *writer) writeS1(data spec.S1Spec, unionNo int) {
+	w.record = "S1"
 	w.writeStr("S1")
-	w.writeInt(unionNo, 2)
-	w.writeInt(data.LocNum, 4)
-	w.writeInt(data.CompanyNum, 10)
-	w.writeStrR(data.CompanyName, 24)
-	w.writeStr("0")
-	w.writeInt(data.Period, 2)
-	w.writeInt(data.Year, 2)
+	w.writeInt("förbund", unionNo, 2)
+	w.writeInt("plats", data.LocNum, 4)
+	w.writeInt("orgnr", data.CompanyNum, 10)
+	w.writeStrR("företagsnamn", data.CompanyName, 24)
+	w.writeInt("redovisningstyp", int(data.AccountingType), 1)
+	w.writeInt("period", data.Period, 2)
+	w.writeInt("år", data.Year, 2)
 	w.writeStr(padDate(data.TransactionDate))
 	w.writeStr("0000000000000\r\n")
 }
 
 func (w *writer) writeS2(data spec.S2Spec, unionNo int) {
+	w.record = "S2"
 	w.writeStr("S2")
-	w.writeInt(unionNo, 2)
-	w.writeInt(data.LocNum, 4)
-	w.writeInt(data.PersonNum, 10)
-	w.writeStr(padRight(strings.ToUpper(data.Name), 24))
-	w.writeAmount(data.Amount, 4, 2)
-	w.writeAmount(data.ControlAmount, 4, 2)
-
-	w.writeInt(int(data.PayCode), 2)
+	w.writeInt("förbund", unionNo, 2)
+	w.writeInt("plats", data.LocNum, 4)
+	w.writeInt("personnummer", data.PersonNum, 10)
+	w.writeStrR("namn", data.Name, 24)
+	w.writeAmount("belopp", data.Amount, 4, 2)
+	w.writeAmount("kontrollbelopp", data.ControlAmount, 4, 2)
+
+	w.writeInt("betalkod", int(data.PayCode), 2)
 	w.writeStr("0000000000\r\n")
-	// fmt.Printf("%s = %s\n", data.Name, data.Amount.StringFixed(2))
 }
 
 func (w *writer) writeS3(data spec.S3Spec, unionNo int) {
+	w.record = "S3"
 	w.writeStr("S3")
-	w.writeInt(unionNo, 2)
-	w.writeInt(data.LocNum, 4)
-	w.writeInt(data.CompanyNum, 10)
-	w.writeStr(padRight(strings.ToUpper(data.CompanyName), 24))
-	w.writeInt(data.Records, 6)
-	w.writeAmount(data.SumAmout, 7, 2)
+	w.writeInt("förbund", unionNo, 2)
+	w.writeInt("plats", data.LocNum, 4)
+	w.writeInt("orgnr", data.CompanyNum, 10)
+	w.writeStrR("företagsnamn", data.CompanyName, 24)
+	w.writeInt("antal", data.Records, 6)
+	w.writeAmount("summa", data.SumAmout, 7, 2)
 	w.writeStr("000000000\r\n")
 }
 
+// DefaultEncoding is what IF-Metall has confirmed they can read
+const DefaultEncoding = "windows-1252"
+
+var encodings = map[string]encoding.Encoding{
+	"windows-1252": charmap.Windows1252,
+	"cp1252":       charmap.Windows1252,
+	"ansi":         charmap.Windows1252,
+	"iso-8859-1":   charmap.ISO8859_1,
+	"latin1":       charmap.ISO8859_1,
+}
+
+// Encoding returns the character encoding with the given name
+func Encoding(name string) (encoding.Encoding, error) {
+	if name == "" {
+		name = DefaultEncoding
+	}
+	enc, ok := encodings[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported encoding %q", name)
+	}
+	return enc, nil
+}
+
+// WriteTable writes locs in the default encoding
 func WriteTable(iw io.Writer, locs spec.Locations, unionNo UnionCode) error {
+	return WriteTableEncoding(iw, locs, unionNo, charmap.Windows1252)
+}
 
-	// which encoding?
-	enc := transform.NewWriter(iw, charmap.Windows1252.NewEncoder())
+// WriteTableEncoding writes locs using the character encoding e
+func WriteTableEncoding(iw io.Writer, locs spec.Locations, unionNo UnionCode, e encoding.Encoding) error {
 	w := writer{
-		buf: bufio.NewWriter(enc),
+		buf:   bufio.NewWriter(transform.NewWriter(iw, e.NewEncoder())),
+		enc:   e,
+		check: e.NewEncoder(),
 	}
-	for locnum := range locs {
+	for _, locnum := range slices.Sorted(maps.Keys(locs)) {
+		w.location = locnum
 		w.writeS1(locs[locnum].S1, int(unionNo))
-		for _, s2 := range locs[locnum].S2 {
+		for i, s2 := range locs[locnum].S2 {
+			w.row = i + 1
 			w.writeS2(s2, int(unionNo))
 		}
 		w.writeS3(locs[locnum].S3, int(unionNo))
 	}
-	err := w.buf.Flush()
-
-	return err
+	if w.err != nil {
+		return w.err
+	}
+	return w.buf.Flush()
 }
 
 func Str2UnionCode(s string) (UnionCode, error) {
@@ -5,6 +5,8 @@
 package union
 
 import (
+	"errors"
+	"io"
 	"os"
 	"strings"
 	"testing"
@@ -101,3 +103,48 @@ func TestWriteTable(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteTableErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(loc *spec.Spec)
+		want   any
+		msg    string
+	}{
+		{"tecken", func(loc *spec.Spec) { loc.S2[1].Name = "Łukasz Nowak" },
+			&spec.EncodingError{}, `plats 1, rad 2: tecknet 'Ł' i "Łukasz Nowak" kan inte skrivas med teckenkodningen Windows 1252`},
+		{"belopp", func(loc *spec.Spec) { loc.S2[2].Amount = decimal.New(12000, 0) },
+			&spec.FormatOverflowError{}, "plats 1, rad 3: belopp 12000.00 får inte plats i S2 (6 tecken)"},
+		{"negativt", func(loc *spec.Spec) { loc.S2[0].ControlAmount = decimal.New(-5, 0) },
+			&spec.FormatOverflowError{}, "plats 1, rad 1: kontrollbelopp -5.00 får inte plats"},
+		{"plats", func(loc *spec.Spec) { loc.S1.LocNum = 12345 },
+			&spec.FormatOverflowError{}, "plats 1: plats 12345 får inte plats i S1 (4 tecken)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := testLocations[1]
+			loc.S2 = append([]spec.S2Spec{}, loc.S2...)
+			tt.change(&loc)
+			err := WriteTable(io.Discard, spec.Locations{1: loc}, CodeIFMetall)
+			if err == nil || !strings.Contains(err.Error(), tt.msg) {
+				t.Fatalf("WriteTable() error = %v, want %q", err, tt.msg)
+			}
+			var ve *spec.ValidationError
+			if !errors.As(err, &ve) || ve.Location != 1 {
+				t.Errorf("WriteTable() error = %#v, want *spec.ValidationError", err)
+			}
+			switch tt.want.(type) {
+			case *spec.EncodingError:
+				var ee *spec.EncodingError
+				if !errors.As(err, &ee) || ee.Rune != 'Ł' {
+					t.Errorf("WriteTable() error = %#v, want *spec.EncodingError", err)
+				}
+			case *spec.FormatOverflowError:
+				var oe *spec.FormatOverflowError
+				if !errors.As(err, &oe) {
+					t.Errorf("WriteTable() error = %#v, want *spec.FormatOverflowError", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package union
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	maxAmount    = decimal.New(10000, 0)    // 4 integer digits
+	maxSumAmount = decimal.New(10000000, 0) // 7 integer digits
+)
+
+func invalid(locnum, row int, field, format string, args ...any) error {
+	return &spec.ValidationError{Location: locnum, Record: row, Field: field, Msg: fmt.Sprintf(format, args...)}
+}
+
+func overflow(locnum, row int, record, field string, v decimal.Decimal, width int) error {
+	err := &spec.FormatOverflowError{Record: record, Field: field, Value: v.StringFixed(2), Width: width}
+	return &spec.ValidationError{Location: locnum, Record: row, Field: field, Msg: err.Error(), Err: err}
+}
+
+// Validate checks that locations are consistent and fit the file format.
+// All problems found are returned joined together, each is a
+// *spec.ValidationError.
+func Validate(locs spec.Locations) error {
+	var errs []error
+	keys := make([]int, 0, len(locs))
+	for k := range locs {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	for _, locnum := range keys {
+		loc := locs[locnum]
+		if loc.S1.LocNum != locnum || loc.S3.LocNum != locnum {
+			errs = append(errs, invalid(locnum, 0, "plats", "S1/S3 har plats %d/%d", loc.S1.LocNum, loc.S3.LocNum))
+		}
+		if loc.S1.CompanyNum <= 0 || loc.S1.CompanyNum > 9999999999 {
+			errs = append(errs, invalid(locnum, 0, "orgnr", "felaktigt orgnr %d", loc.S1.CompanyNum))
+		}
+		if loc.S1.Period < 1 || loc.S1.Period > 12 {
+			errs = append(errs, invalid(locnum, 0, "period", "felaktig period %d", loc.S1.Period))
+		}
+		sum := decimal.Zero
+		for i, s2 := range loc.S2 {
+			if s2.PersonNum <= 0 || s2.PersonNum > 9999999999 {
+				errs = append(errs, invalid(locnum, i+1, "personnummer", "felaktigt personnummer %d", s2.PersonNum))
+			}
+			if s2.Name == "" {
+				errs = append(errs, invalid(locnum, i+1, "namn", "namn saknas"))
+			}
+			if s2.Amount.IsNegative() || s2.Amount.GreaterThanOrEqual(maxAmount) {
+				errs = append(errs, overflow(locnum, i+1, "S2", "belopp", s2.Amount, 6))
+			}
+			sum = sum.Add(s2.Amount)
+		}
+		if loc.S3.Records != len(loc.S2) {
+			errs = append(errs, invalid(locnum, 0, "antal", "S3 anger %d poster, hittade %d", loc.S3.Records, len(loc.S2)))
+		}
+		if !loc.S3.SumAmout.Equal(sum) {
+			errs = append(errs, invalid(locnum, 0, "summa", "S3 anger summa %s, beräknad %s", loc.S3.SumAmout, sum))
+		}
+		if loc.S3.SumAmout.GreaterThanOrEqual(maxSumAmount) {
+			errs = append(errs, overflow(locnum, 0, "S3", "summa", loc.S3.SumAmout, 9))
+		}
+	}
+	return errors.Join(errs...)
+}
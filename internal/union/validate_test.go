@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package union
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/shopspring/decimal"
+)
+
+func TestValidate(t *testing.T) {
+	if err := Validate(testLocations); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	loc := testLocations[1]
+	bad := loc
+	bad.S2 = append([]spec.S2Spec{}, loc.S2...)
+	bad.S2[0].Amount = decimal.New(12000, 0)
+	err := Validate(spec.Locations{1: bad})
+	if err == nil {
+		t.Fatal("Validate() expected error for amount and sum")
+	}
+	var ve *spec.ValidationError
+	if !errors.As(err, &ve) || ve.Location != 1 || ve.Record != 1 || ve.Field != "belopp" {
+		t.Errorf("Validate() error = %#v, want belopp in record 1", ve)
+	}
+	var oe *spec.FormatOverflowError
+	if !errors.As(err, &oe) || oe.Record != "S2" {
+		t.Errorf("Validate() error = %v, want *spec.FormatOverflowError", err)
+	}
+}
@@ -0,0 +1,58 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package worker
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// RlimitsSupported tells if the child can restrict itself
+const RlimitsSupported = true
+
+func restrict(rl Rlimits) error {
+	if rl.CPU > 0 {
+		secs := uint64(math.Ceil(rl.CPU.Seconds()))
+		if err := syscall.Setrlimit(syscall.RLIMIT_CPU, &syscall.Rlimit{Cur: secs, Max: secs}); err != nil {
+			return fmt.Errorf("rlimit cpu: %w", err)
+		}
+	}
+	if rl.Memory > 0 {
+		// RLIMIT_AS would include the address space the Go runtime
+		// reserves, so limit the data segment on top of what is in use
+		limit := uint64(dataSize() + rl.Memory)
+		if err := syscall.Setrlimit(syscall.RLIMIT_DATA, &syscall.Rlimit{Cur: limit, Max: limit}); err != nil {
+			return fmt.Errorf("rlimit minne: %w", err)
+		}
+		// collect garbage harder before hitting the limit
+		debug.SetMemoryLimit(rl.Memory / 2)
+	}
+	return nil
+}
+
+// dataSize returns VmData of the process in bytes, 0 if unknown
+func dataSize() int64 {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if v, ok := strings.CutPrefix(s.Text(), "VmData:"); ok {
+			kb, _ := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(v), " kB"), 10, 64)
+			return kb * 1024
+		}
+	}
+	return 0
+}
@@ -0,0 +1,14 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+//go:build !linux
+
+package worker
+
+// RlimitsSupported tells if the child can restrict itself
+const RlimitsSupported = false
+
+func restrict(rl Rlimits) error {
+	return nil
+}
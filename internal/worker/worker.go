@@ -0,0 +1,209 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+// Package worker parses pdfs in a child process, so that a crash or a
+// memory blow-up in the pdf library only kills the child and not the
+// server holding the session keys. The child is the program itself,
+// started with arguments that make it call Serve.
+//
+// The parent writes a request line with the limits followed by the pdf
+// on stdin. The child restricts itself, parses and writes the document
+// as saved by parser.Document.Save, or an error, on stdout.
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/kmpm/unionfees/internal/parser"
+	"github.com/kmpm/unionfees/public/spec"
+)
+
+// Rlimits are the resource limits the child sets on itself before
+// parsing. They are only applied where RlimitsSupported is true.
+type Rlimits struct {
+	CPU    time.Duration // cpu time, 0 for no limit
+	Memory int64         // bytes the child may allocate when parsing, 0 for no limit
+}
+
+// exitFailed is the exit code of a child that wrote an error response,
+// anything else than 0 or exitFailed means it crashed
+const exitFailed = 1
+
+// grace is how long the parent waits for the child after the parse
+// timeout before killing it, the child should time out itself first
+const grace = time.Second
+
+type request struct {
+	MaxSize   int64         `json:"maxSize"`
+	MaxPages  int           `json:"maxPages"`
+	Timeout   time.Duration `json:"timeout"`
+	MaxStream int64         `json:"maxStream"`
+	MaxTexts  int           `json:"maxTexts"`
+	CPU       time.Duration `json:"cpu"`
+	Memory    int64         `json:"memory"`
+}
+
+type response struct {
+	Error string `json:"error"`
+	Kind  string `json:"kind"` // parse, limit or empty
+	Page  int    `json:"page,omitempty"`
+}
+
+// limitError is an ErrLimit from the child with its message
+type limitError struct{ msg string }
+
+func (e *limitError) Error() string        { return e.msg }
+func (e *limitError) Is(target error) bool { return target == parser.ErrLimit }
+
+// Serve is the child side. It returns an error after writing it to w,
+// the program should then exit with ExitCode.
+func Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("läsning av förfrågan: %w", err)
+	}
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return fmt.Errorf("felaktig förfrågan: %w", err)
+	}
+	if err := restrict(Rlimits{CPU: req.CPU, Memory: req.Memory}); err != nil {
+		return respond(w, err)
+	}
+	var in io.Reader = br
+	if req.MaxSize > 0 {
+		in = io.LimitReader(br, req.MaxSize+1)
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return respond(w, err)
+	}
+	lim := parser.Limits{MaxSize: req.MaxSize, MaxPages: req.MaxPages, Timeout: req.Timeout,
+		MaxStream: req.MaxStream, MaxTexts: req.MaxTexts}
+	doc, err := parser.Read(ctx, bytes.NewReader(data), int64(len(data)), lim)
+	if err != nil {
+		return respond(w, err)
+	}
+	return doc.Save(w)
+}
+
+// ExitCode is the code for the child to exit with after Serve returned err
+func ExitCode(err error) int {
+	if err != nil {
+		return exitFailed
+	}
+	return 0
+}
+
+func respond(w io.Writer, err error) error {
+	res := response{Error: err.Error()}
+	var pe *spec.ParseError
+	switch {
+	case errors.Is(err, parser.ErrLimit):
+		res.Kind = "limit"
+	case errors.As(err, &pe):
+		res.Kind, res.Error, res.Page = "parse", pe.Err.Error(), pe.Page
+	}
+	if jerr := json.NewEncoder(w).Encode(res); jerr != nil {
+		return jerr
+	}
+	return err
+}
+
+// Process starts a child for every pdf to parse
+type Process struct {
+	// Path is the executable, the running program if empty
+	Path string
+	// Args make the executable call Serve
+	Args    []string
+	Rlimits Rlimits
+}
+
+// Read parses data in a new child process, it can be used in place of
+// parser.Read. A child that crashes or is killed gives an error wrapping
+// parser.ErrLimit, as that is the likely cause.
+func (p *Process) Read(ctx context.Context, data []byte, lim parser.Limits) (*parser.Document, error) {
+	if lim.MaxSize > 0 && int64(len(data)) > lim.MaxSize {
+		return &parser.Document{}, fmt.Errorf("%w: %d byte, max %d", parser.ErrLimit, len(data), lim.MaxSize)
+	}
+	path := p.Path
+	if path == "" {
+		var err error
+		if path, err = os.Executable(); err != nil {
+			return &parser.Document{}, err
+		}
+	}
+	if lim.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, lim.Timeout+grace,
+			fmt.Errorf("%w: tolkningen tog mer än %s", parser.ErrLimit, lim.Timeout))
+		defer cancel()
+	}
+	req, err := json.Marshal(request{
+		MaxSize:   lim.MaxSize,
+		MaxPages:  lim.MaxPages,
+		Timeout:   lim.Timeout,
+		MaxStream: lim.MaxStream,
+		MaxTexts:  lim.MaxTexts,
+		CPU:       p.Rlimits.CPU,
+		Memory:    p.Rlimits.Memory,
+	})
+	if err != nil {
+		return &parser.Document{}, err
+	}
+
+	cmd := exec.CommandContext(ctx, path, p.Args...)
+	// nothing from the environment of the parent, like SESSION_KEY
+	cmd.Env = []string{}
+	cmd.Stdin = io.MultiReader(bytes.NewReader(req), strings.NewReader("\n"), bytes.NewReader(data))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = grace
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return &parser.Document{}, context.Cause(ctx)
+	}
+	if err == nil {
+		return parser.Load(&stdout)
+	}
+	var ee *exec.ExitError
+	if errors.As(err, &ee) && ee.ExitCode() == exitFailed {
+		var res response
+		if jerr := json.Unmarshal(stdout.Bytes(), &res); jerr == nil && res.Error != "" {
+			return &parser.Document{}, res.err()
+		}
+	}
+	slog.Error("pdf worker failed", "error", err, "stderr", lastLine(stderr.String()))
+	return &parser.Document{}, fmt.Errorf("%w: tolkningen avbröts (%v)", parser.ErrLimit, err)
+}
+
+func (r response) err() error {
+	switch r.Kind {
+	case "limit":
+		return &limitError{r.Error}
+	case "parse":
+		return &spec.ParseError{Page: r.Page, Err: errors.New(r.Error)}
+	}
+	return errors.New(r.Error)
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
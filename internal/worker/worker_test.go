@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kmpm/unionfees/internal/parser"
+	"github.com/kmpm/unionfees/internal/pdftest"
+	"github.com/kmpm/unionfees/public/spec"
+)
+
+// TestMain lets the test binary act as the child, like the server does
+// with -worker
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "worker":
+			err := Serve(context.Background(), os.Stdin, os.Stdout)
+			os.Exit(ExitCode(err))
+		case "crash":
+			os.Exit(2)
+		case "sleep":
+			time.Sleep(time.Minute)
+			os.Exit(0)
+		}
+	}
+	os.Exit(m.Run())
+}
+
+var testReport = pdftest.Example()
+
+func testData(t *testing.T) []byte {
+	t.Helper()
+	data, err := pdftest.Bytes(testReport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func child(args ...string) *Process {
+	return &Process{Path: os.Args[0], Args: args}
+}
+
+func TestRead(t *testing.T) {
+	data := testData(t)
+	doc, err := child("worker").Read(context.Background(), data, parser.DefaultLimits)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if got, want := doc.GetTables(), testReport.Tables(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetTables() = %v\nwant %v", got, want)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	data := testData(t)
+	// makes the pdf library panic when the page content is read
+	broken := bytes.ReplaceAll(data, []byte("stream"), []byte("streem"))
+
+	tests := []struct {
+		name    string
+		p       *Process
+		data    []byte
+		lim     parser.Limits
+		want    error
+		wantErr string
+	}{
+		{"storlek", child("worker"), data, parser.Limits{MaxSize: 100}, parser.ErrLimit, "byte, max 100"},
+		// the limit comes from the child
+		{"sidor", child("worker"), bytes.Replace(data, []byte("/Count 2"), []byte("/Count 9"), 1),
+			parser.Limits{MaxPages: 5}, parser.ErrLimit, "9 sidor, max 5"},
+		{"kraschar", child("crash"), data, parser.Limits{}, parser.ErrLimit, "tolkningen avbröts"},
+		{"tid", child("sleep"), data, parser.Limits{Timeout: time.Millisecond}, parser.ErrLimit, "tog mer än 1ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.p.Read(context.Background(), tt.data, tt.lim)
+			if !errors.Is(err, tt.want) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Read() error = %v, want %v %q", err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	_, err := child("worker").Read(context.Background(), broken, parser.Limits{})
+	var pe *spec.ParseError
+	if !errors.As(err, &pe) || pe.Page != 1 {
+		t.Errorf("Read() error = %v, want *spec.ParseError on page 1", err)
+	}
+}
+
+func TestServe(t *testing.T) {
+	data := testData(t)
+	in := `{"maxSize": 100}` + "\n" + string(data)
+	var out bytes.Buffer
+	err := Serve(context.Background(), strings.NewReader(in), &out)
+	if !errors.Is(err, parser.ErrLimit) || ExitCode(err) != exitFailed {
+		t.Fatalf("Serve() error = %v, want %v", err, parser.ErrLimit)
+	}
+	if got, want := out.String(), `"kind":"limit"`; !strings.Contains(got, want) {
+		t.Errorf("Serve() wrote %s, want %s", got, want)
+	}
+
+	if err := Serve(context.Background(), strings.NewReader("inte json\n"), &out); err == nil {
+		t.Error("Serve() of a bad request should fail")
+	}
+}
@@ -0,0 +1,176 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package xlsx
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kmpm/unionfees/internal"
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/shopspring/decimal"
+)
+
+// Member list columns, in the order they are written
+const (
+	ColName      = "Namn"
+	ColPersonNum = "Personnummer"
+	ColAmount    = "Belopp"
+	ColPayCode   = "Betalkod"
+	ColLocation  = "Plats"
+	// ColUnion has the full union name, the sheet name may be shortened
+	ColUnion = "Förbund"
+)
+
+var memberColumns = []string{ColName, ColPersonNum, ColAmount, ColPayCode, ColLocation}
+
+// Members is the member list of a union, one sheet in the workbook
+type Members struct {
+	// Union is the union name, the sheet name is shortened and cleaned
+	// to what Excel allows so it is also written in the column ColUnion
+	Union string
+	S2    []spec.S2Spec
+}
+
+// FormatPersonNum writes a 10 digit personnummer as ÅÅMMDD-NNNN
+func FormatPersonNum(n int) string {
+	return fmt.Sprintf("%06d-%04d", n/10000, n%10000)
+}
+
+// WriteMembers writes a workbook with a sheet per union
+func WriteMembers(w io.Writer, lists []Members) error {
+	sheets := make([]Sheet, 0, len(lists))
+	for _, l := range lists {
+		s := Sheet{Name: l.Union, Header: true, Rows: [][]Cell{{}}}
+		for _, c := range memberColumns {
+			s.Rows[0] = append(s.Rows[0], Str(c))
+		}
+		s.Rows[0] = append(s.Rows[0], Str(ColUnion))
+		for _, s2 := range l.S2 {
+			s.Rows = append(s.Rows, []Cell{
+				Str(s2.Name),
+				Str(FormatPersonNum(s2.PersonNum)),
+				Num(s2.Amount),
+				Int(int(s2.PayCode)),
+				Int(s2.LocNum),
+				Str(l.Union),
+			})
+		}
+		if len(l.S2) == 0 {
+			// keeps the name of a union without members
+			row := make([]Cell, len(memberColumns), len(memberColumns)+1)
+			s.Rows = append(s.Rows, append(row, Str(l.Union)))
+		}
+		sheets = append(sheets, s)
+	}
+	return Write(w, sheets)
+}
+
+// ReadMembers reads member lists written by WriteMembers and possibly
+// edited in Excel. Columns are found by their headers, rows without
+// values are skipped. The union name is read from the column ColUnion,
+// or is the sheet name in lists without it. Line in the provenance and in
+// a *spec.ParseError is the row in Excel. See Read for limit.
+func ReadMembers(r io.ReaderAt, size, limit int64) ([]Members, error) {
+	sheets, err := Read(r, size, limit)
+	if err != nil {
+		return nil, err
+	}
+	lists := []Members{}
+	for _, s := range sheets {
+		rows := s.Strings()
+		if len(rows) == 0 {
+			continue
+		}
+		idx := map[string]int{}
+		for _, c := range memberColumns {
+			i := slices.IndexFunc(rows[0], func(h string) bool { return strings.EqualFold(strings.TrimSpace(h), c) })
+			if i < 0 {
+				return nil, &spec.ParseError{Sheet: s.Name, Line: 1, Err: fmt.Errorf("kolumnen %s saknas", c)}
+			}
+			idx[c] = i
+		}
+		unionCol := slices.IndexFunc(rows[0], func(h string) bool { return strings.EqualFold(strings.TrimSpace(h), ColUnion) })
+		name := ""
+		l := Members{S2: []spec.S2Spec{}}
+		for i, row := range rows[1:] {
+			line := i + 2
+			cell := func(c string) string {
+				if idx[c] < len(row) {
+					return strings.TrimSpace(row[idx[c]])
+				}
+				return ""
+			}
+			if unionCol >= 0 && unionCol < len(row) {
+				if u := strings.TrimSpace(row[unionCol]); u != "" && name == "" {
+					name = u
+				} else if u != "" && u != name {
+					return nil, &spec.ParseError{Sheet: s.Name, Line: line, Err: fmt.Errorf("%s: %q, men tidigare rader har %q", ColUnion, u, name)}
+				}
+			}
+			if !slices.ContainsFunc(memberColumns, func(c string) bool { return cell(c) != "" }) {
+				continue
+			}
+			s2, err := memberRow(cell)
+			if err != nil {
+				return nil, &spec.ParseError{Sheet: s.Name, Line: line, Err: err}
+			}
+			cells := []string{}
+			for _, c := range memberColumns {
+				cells = append(cells, cell(c))
+			}
+			s2.Source = &spec.Provenance{
+				Line:  line,
+				Cells: cells,
+				Steps: []string{fmt.Sprintf("granskad i xlsx, blad %q", s.Name)},
+			}
+			l.S2 = append(l.S2, s2)
+		}
+		l.Union = cmp.Or(name, s.Name)
+		lists = append(lists, l)
+	}
+	return lists, nil
+}
+
+func memberRow(cell func(string) string) (spec.S2Spec, error) {
+	s2 := spec.S2Spec{Name: cell(ColName), PayCode: spec.PayCodeAmountPayed}
+	if s2.Name == "" {
+		return s2, fmt.Errorf("%s saknas", ColName)
+	}
+	pnr := cell(ColPersonNum)
+	// 9 digits if Excel made it a number and dropped a leading zero
+	if n := len(strings.ReplaceAll(pnr, "-", "")); n != 9 && n != 10 && n != 12 {
+		return s2, fmt.Errorf("%s: %q har fel längd", ColPersonNum, pnr)
+	}
+	var err error
+	if s2.PersonNum, err = internal.Str2Person(pnr); err != nil {
+		return s2, fmt.Errorf("%s: %w", ColPersonNum, err)
+	}
+	amount := strings.NewReplacer(" ", "", " ", "", ",", ".").Replace(cell(ColAmount))
+	if amount == "" {
+		amount = "0"
+	}
+	d, err := decimal.NewFromString(amount)
+	if err != nil {
+		return s2, fmt.Errorf("%s: %w", ColAmount, err)
+	}
+	// Excel may store 412.1 as 412.09999999999999
+	s2.Amount = d.Round(2)
+	if v := cell(ColPayCode); v != "" {
+		code, err := strconv.Atoi(v)
+		if err != nil {
+			return s2, fmt.Errorf("%s: %w", ColPayCode, err)
+		}
+		s2.PayCode = spec.PayCode(code)
+	}
+	if s2.LocNum, err = strconv.Atoi(cell(ColLocation)); err != nil {
+		return s2, fmt.Errorf("%s: %w", ColLocation, err)
+	}
+	return s2, nil
+}
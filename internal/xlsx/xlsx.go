@@ -0,0 +1,417 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+// Package xlsx reads and writes the parts of Excel workbooks needed for
+// member lists: sheets with text and number cells. Formulas, dates and
+// formatting beyond a bold header and two decimals are not supported.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/shopspring/decimal"
+)
+
+// Cell is a value in a sheet
+type Cell struct {
+	Value  string
+	Number bool // written as a number with two decimals
+}
+
+// Str is a text cell
+func Str(s string) Cell { return Cell{Value: s} }
+
+// Num is a number cell with two decimals
+func Num(d decimal.Decimal) Cell { return Cell{Value: d.StringFixed(2), Number: true} }
+
+// Int is a number cell without decimals
+func Int(i int) Cell { return Cell{Value: strconv.Itoa(i), Number: true} }
+
+// Sheet is a named sheet, Rows[0] is row 1 in Excel
+type Sheet struct {
+	Name string
+	// Header makes the first row bold and keeps it visible when scrolling
+	Header bool
+	Rows   [][]Cell
+}
+
+// Strings returns the values of the cells per row
+func (s Sheet) Strings() [][]string {
+	rows := make([][]string, len(s.Rows))
+	for i, r := range s.Rows {
+		rows[i] = make([]string, len(r))
+		for j, c := range r {
+			rows[i][j] = c.Value
+		}
+	}
+	return rows
+}
+
+const (
+	nsMain    = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	nsRel     = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	nsPkgRel  = "http://schemas.openxmlformats.org/package/2006/relationships"
+	typeSheet = "application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"
+	xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+)
+
+const styles = xmlHeader + `<styleSheet xmlns="` + nsMain + `">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+// style index in cellXfs
+const (
+	styleBold   = 1
+	styleAmount = 2
+)
+
+// SheetName returns name as a valid and unique sheet name, at most 31
+// characters without []:*?/\
+func SheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		name = "Blad"
+	}
+	base := truncate(name, 31)
+	name = base
+	for i := 2; used[strings.ToLower(name)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		name = truncate(base, 31-len(suffix)) + suffix
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// ColumnName returns the column letters for the 0 based index, A, B ... AA
+func ColumnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// Write writes the sheets as a workbook
+func Write(w io.Writer, sheets []Sheet) error {
+	if len(sheets) == 0 {
+		return errors.New("arbetsboken måste ha minst ett blad")
+	}
+	zw := zip.NewWriter(w)
+	file := func(name, content string) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(f, content)
+		return err
+	}
+
+	var types, wbSheets, wbRels strings.Builder
+	used := map[string]bool{}
+	for i, s := range sheets {
+		n := i + 1
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="%s"/>`, n, typeSheet)
+		fmt.Fprintf(&wbSheets, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(SheetName(s.Name, used)), n, n)
+		fmt.Fprintf(&wbRels, `<Relationship Id="rId%d" Type="%s/worksheet" Target="worksheets/sheet%d.xml"/>`, n, nsRel, n)
+	}
+	fmt.Fprintf(&wbRels, `<Relationship Id="rId%d" Type="%s/styles" Target="styles.xml"/>`, len(sheets)+1, nsRel)
+
+	files := []struct{ name, content string }{
+		{"[Content_Types].xml", xmlHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			types.String() + `</Types>`},
+		{"_rels/.rels", xmlHeader + `<Relationships xmlns="` + nsPkgRel + `">` +
+			`<Relationship Id="rId1" Type="` + nsRel + `/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", xmlHeader + `<workbook xmlns="` + nsMain + `" xmlns:r="` + nsRel + `"><sheets>` +
+			wbSheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xmlHeader + `<Relationships xmlns="` + nsPkgRel + `">` + wbRels.String() + `</Relationships>`},
+		{"xl/styles.xml", styles},
+	}
+	for i, s := range sheets {
+		files = append(files, struct{ name, content string }{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheetXML(s)})
+	}
+	for _, f := range files {
+		if err := file(f.name, f.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func sheetXML(s Sheet) string {
+	var b strings.Builder
+	b.WriteString(xmlHeader + `<worksheet xmlns="` + nsMain + `">`)
+	if s.Header && len(s.Rows) > 0 {
+		b.WriteString(`<sheetViews><sheetView workbookViewId="0">` +
+			`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	}
+
+	// column widths from the longest value
+	widths := []int{}
+	for _, row := range s.Rows {
+		for j, c := range row {
+			if j >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[j] = max(widths[j], utf8.RuneCountInString(c.Value))
+		}
+	}
+	if len(widths) > 0 {
+		b.WriteString("<cols>")
+		for j, w := range widths {
+			fmt.Fprintf(&b, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, j+1, j+1, min(max(w+2, 8), 60))
+		}
+		b.WriteString("</cols>")
+	}
+
+	b.WriteString("<sheetData>")
+	for i, row := range s.Rows {
+		fmt.Fprintf(&b, `<row r="%d">`, i+1)
+		for j, c := range row {
+			ref := ColumnName(j) + strconv.Itoa(i+1)
+			switch {
+			case c.Value == "":
+			case c.Number && !(s.Header && i == 0):
+				style := ""
+				if strings.Contains(c.Value, ".") {
+					style = fmt.Sprintf(` s="%d"`, styleAmount)
+				}
+				fmt.Fprintf(&b, `<c r="%s"%s><v>%s</v></c>`, ref, style, escape(c.Value))
+			default:
+				style := ""
+				if s.Header && i == 0 {
+					style = fmt.Sprintf(` s="%d"`, styleBold)
+				}
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"%s><is><t xml:space="preserve">%s</t></is></c>`, ref, style, escape(c.Value))
+			}
+		}
+		b.WriteString("</row>")
+	}
+	b.WriteString("</sheetData></worksheet>")
+	return b.String()
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+type xmlWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		ID   string `xml:"id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xmlRels struct {
+	Rels []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xmlText is a shared string or an inline string, rich text has runs
+type xmlText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xmlText) String() string {
+	s := t.T
+	for _, r := range t.Runs {
+		s += r.T
+	}
+	return s
+}
+
+type xmlSheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R  string  `xml:"r,attr"`
+			T  string  `xml:"t,attr"`
+			V  string  `xml:"v"`
+			Is xmlText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// The size of a sheet in Excel, columns A to XFD
+const (
+	maxColumns = 16384
+	maxRows    = 1048576
+)
+
+// ErrTooLarge is a part of the workbook that is larger than the limit
+// when it is unpacked
+var ErrTooLarge = errors.New("xlsx-filen är för stor att läsa")
+
+// Read reads all sheets of a workbook of size bytes. No part of the
+// workbook may be larger than limit bytes unpacked, 0 for no limit.
+// Cells and rows outside of what Excel allows give a *spec.ParseError.
+func Read(r io.ReaderAt, size, limit int64) ([]Sheet, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("inte en xlsx-fil: %w", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	decode := func(name string, v any) error {
+		f, ok := files[name]
+		if !ok {
+			return fmt.Errorf("%s saknas i xlsx-filen", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		var in io.Reader = rc
+		if limit > 0 {
+			in = io.LimitReader(rc, limit+1)
+		}
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if limit > 0 && int64(len(data)) > limit {
+			return fmt.Errorf("%s: %w: mer än %d byte uppackad", name, ErrTooLarge, limit)
+		}
+		if err := xml.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	}
+
+	var wb xmlWorkbook
+	if err := decode("xl/workbook.xml", &wb); err != nil {
+		return nil, err
+	}
+	var rels xmlRels
+	if err := decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	var shared struct {
+		SI []xmlText `xml:"si"`
+	}
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decode("xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+	}
+
+	sheets := []Sheet{}
+	for _, ws := range wb.Sheets {
+		target := ""
+		for _, rel := range rels.Rels {
+			if rel.ID == ws.ID {
+				target = rel.Target
+			}
+		}
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("xl", target)
+		}
+		var xs xmlSheet
+		if err := decode(target, &xs); err != nil {
+			return nil, err
+		}
+		s := Sheet{Name: ws.Name}
+		for i, xr := range xs.Rows {
+			n := xr.R
+			if n == 0 {
+				n = i + 1
+			}
+			if n < 1 || n > maxRows {
+				return nil, &spec.ParseError{Sheet: ws.Name, Err: fmt.Errorf("felaktigt radnummer %d", xr.R)}
+			}
+			for len(s.Rows) < n {
+				s.Rows = append(s.Rows, nil)
+			}
+			row := []Cell{}
+			for j, xc := range xr.Cells {
+				col := j
+				if xc.R != "" {
+					col = columnIndex(xc.R)
+				}
+				if col < 0 || col >= maxColumns {
+					return nil, &spec.ParseError{Sheet: ws.Name, Line: n, Err: fmt.Errorf("felaktig cellreferens %q", xc.R)}
+				}
+				for len(row) <= col {
+					row = append(row, Cell{})
+				}
+				c := Cell{Value: xc.V}
+				switch xc.T {
+				case "s":
+					k, err := strconv.Atoi(xc.V)
+					if err != nil || k < 0 || k >= len(shared.SI) {
+						return nil, fmt.Errorf("%s!%s: felaktig delad text %q", ws.Name, xc.R, xc.V)
+					}
+					c.Value = shared.SI[k].String()
+				case "inlineStr":
+					c.Value = xc.Is.String()
+				case "", "n":
+					c.Number = xc.V != ""
+				}
+				row[col] = c
+			}
+			s.Rows[n-1] = row
+		}
+		sheets = append(sheets, s)
+	}
+	return sheets, nil
+}
+
+// columnIndex returns the 0 based column of a cell reference like AB12,
+// -1 if it has no column or more letters than a column in Excel
+func columnIndex(ref string) int {
+	n := 0
+	for i, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		if i == 3 {
+			return -1
+		}
+		n = n*26 + int(r-'A'+1)
+	}
+	return n - 1
+}
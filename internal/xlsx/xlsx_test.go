@@ -0,0 +1,251 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/shopspring/decimal"
+)
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := ColumnName(i); got != want {
+			t.Errorf("ColumnName(%d) = %s, want %s", i, got, want)
+		}
+		if got := columnIndex(want + "12"); got != i {
+			t.Errorf("columnIndex(%s12) = %d, want %d", want, got, i)
+		}
+	}
+}
+
+func TestSheetName(t *testing.T) {
+	used := map[string]bool{}
+	tests := []struct{ name, want string }{
+		{"IF Metall", "IF Metall"},
+		{"if metall", "if metall (2)"},
+		{"Unionen: tjänstemän [A/B]", "Unionen- tjänstemän -A-B-"},
+		{"Svenska Byggnadsarbetareförbundet avd 1", "Svenska Byggnadsarbetareförbund"},
+		{"", "Blad"},
+	}
+	for _, tt := range tests {
+		if got := SheetName(tt.name, used); got != tt.want {
+			t.Errorf("SheetName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	sheets := []Sheet{
+		{Name: "IF Metall", Header: true, Rows: [][]Cell{
+			{Str("Namn"), Str("Belopp")},
+			{Str("Andersson <Anna> & co"), Num(decimal.RequireFromString("412.5"))},
+			{Str(""), Int(3)},
+		}},
+		{Name: "GS", Rows: [][]Cell{{Str("x")}}},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, sheets); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "IF Metall" || got[1].Name != "GS" {
+		t.Fatalf("Read() = %v", got)
+	}
+	want := [][]string{{"Namn", "Belopp"}, {"Andersson <Anna> & co", "412.50"}, {"", "3"}}
+	if rows := got[0].Strings(); !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+	if !got[0].Rows[1][1].Number || got[0].Rows[1][0].Number {
+		t.Errorf("Number = %v", got[0].Rows[1])
+	}
+}
+
+// excelFile is a workbook as Excel saves it, with shared strings, rich
+// text, skipped rows and cells and floating point noise
+func excelFile(t *testing.T) []byte {
+	t.Helper()
+	return zipFiles(t, map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="IF Metall" sheetId="3" r:id="rId7"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId7" Type="x" Target="/xl/worksheets/blad.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<si><t>Plats</t></si><si><t>Personnummer</t></si><si><t>Namn</t></si><si><t>Belopp</t></si>` +
+			`<si><t>Betalkod</t></si><si><r><t>Andersson </t></r><r><rPr><b/></rPr><t>Anna</t></r></si></sst>`,
+		"xl/worksheets/blad.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+			`<row r="1"><c r="A1" t="s"><v>2</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>3</v></c>` +
+			`<c r="D1" t="s"><v>4</v></c><c r="E1" t="s"><v>0</v></c></row>` +
+			`<row r="3"><c r="A3" t="s"><v>5</v></c><c r="B3"><v>8501011234</v></c><c r="C3"><v>412.10000000000002</v></c>` +
+			`<c r="E3"><v>2</v></c></row>` +
+			`</sheetData></worksheet>`,
+	})
+}
+
+func zipFiles(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// sheetFile is a workbook with one sheet with the rows
+func sheetFile(t *testing.T, rows string) []byte {
+	t.Helper()
+	return zipFiles(t, map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="GS" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml":   `<worksheet><sheetData>` + rows + `</sheetData></worksheet>`,
+	})
+}
+
+func TestReadMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    string
+		line    int
+		wantErr string
+	}{
+		{"kolumn saknas", `<row r="1"><c r="1"><v>1</v></c></row>`, 1, `felaktig cellreferens "1"`},
+		{"för många bokstäver", `<row r="2"><c r="AAAA2"><v>1</v></c></row>`, 2, `felaktig cellreferens "AAAA2"`},
+		{"efter XFD", `<row r="2"><c r="XFE2"><v>1</v></c></row>`, 2, `felaktig cellreferens "XFE2"`},
+		{"rad", `<row r="2000000000"><c r="A1"><v>1</v></c></row>`, 0, "felaktigt radnummer 2000000000"},
+		{"negativ rad", `<row r="-1"><c r="A1"><v>1</v></c></row>`, 0, "felaktigt radnummer -1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := sheetFile(t, tt.rows)
+			_, err := Read(bytes.NewReader(data), int64(len(data)), 0)
+			var pe *spec.ParseError
+			if !errors.As(err, &pe) || pe.Sheet != "GS" || pe.Line != tt.line || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Read() error = %v, want *spec.ParseError on line %d %q", err, tt.line, tt.wantErr)
+			}
+		})
+	}
+
+	// compresses well but is large unpacked
+	data := sheetFile(t, strings.Repeat(`<row><c><v>1</v></c></row>`, 10000))
+	if _, err := Read(bytes.NewReader(data), int64(len(data)), 1<<20); err != nil {
+		t.Errorf("Read() error = %v", err)
+	}
+	if _, err := Read(bytes.NewReader(data), int64(len(data)), 100<<10); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Read() error = %v, want %v", err, ErrTooLarge)
+	}
+}
+
+func TestReadMembersExcel(t *testing.T) {
+	data := excelFile(t)
+	lists, err := ReadMembers(bytes.NewReader(data), int64(len(data)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 || lists[0].Union != "IF Metall" || len(lists[0].S2) != 1 {
+		t.Fatalf("ReadMembers() = %+v", lists)
+	}
+	s2 := lists[0].S2[0]
+	if s2.Name != "Andersson Anna" || s2.PersonNum != 8501011234 || s2.Amount.String() != "412.1" ||
+		s2.PayCode != spec.PayCodeAmountPayed || s2.LocNum != 2 {
+		t.Errorf("S2 = %+v", s2)
+	}
+	if s2.Source == nil || s2.Source.Line != 3 {
+		t.Errorf("Source = %+v, want line 3", s2.Source)
+	}
+}
+
+func TestMembersRoundTrip(t *testing.T) {
+	lists := []Members{{Union: "GS", S2: []spec.S2Spec{
+		{LocNum: 1, PersonNum: 101011234, Name: "Öberg Åsa", Amount: decimal.RequireFromString("295.25"), PayCode: spec.PayCodeAmountPayed},
+		{LocNum: 2, PersonNum: 7902022345, Name: "Bengtsson Bertil", Amount: decimal.Zero, PayCode: spec.PayCodeEndEmployment},
+	}}, {
+		// longer than a sheet name may be and with characters it may not have
+		Union: "Svenska Kommunalarbetareförbundet avd [3]/Väst", S2: []spec.S2Spec{
+			{LocNum: 1, PersonNum: 8501011234, Name: "Andersson Anna", Amount: decimal.RequireFromString("412"), PayCode: spec.PayCodeAmountPayed},
+		},
+	}, {
+		Union: "Unionen: tjänstemän utan medlemmar denna månad", S2: []spec.S2Spec{},
+	}}
+	var buf bytes.Buffer
+	if err := WriteMembers(&buf, lists); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadMembers(bytes.NewReader(buf.Bytes()), int64(buf.Len()), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for j := range got {
+		if j >= len(lists) || len(got[j].S2) != len(lists[j].S2) {
+			t.Fatalf("ReadMembers() = %+v\nwant %+v", got, lists)
+		}
+		for i := range got[j].S2 {
+			if !got[j].S2[i].Amount.Equal(lists[j].S2[i].Amount) {
+				t.Errorf("Amount = %s, want %s", got[j].S2[i].Amount, lists[j].S2[i].Amount)
+			}
+			got[j].S2[i].Amount = lists[j].S2[i].Amount
+			got[j].S2[i].Source = nil
+		}
+	}
+	if !reflect.DeepEqual(got, lists) {
+		t.Errorf("ReadMembers() = %+v\nwant %+v", got, lists)
+	}
+}
+
+func TestReadMembersErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    [][]Cell
+		wantErr string
+	}{
+		{"kolumn saknas", [][]Cell{{Str("Namn"), Str("Personnummer")}}, "kolumnen Belopp saknas"},
+		{"felaktigt personnummer", [][]Cell{
+			{Str("Namn"), Str("Personnummer"), Str("Belopp"), Str("Betalkod"), Str("Plats")},
+			{Str("Andersson Anna"), Str("85-01"), Str("412"), Str("1"), Str("1")},
+		}, `blad "GS" rad 2: Personnummer`},
+		{"belopp", [][]Cell{
+			{Str("Namn"), Str("Personnummer"), Str("Belopp"), Str("Betalkod"), Str("Plats")},
+			{},
+			{Str("Andersson Anna"), Str("850101-1234"), Str("fyra"), Str("1"), Str("1")},
+		}, `rad 3: Belopp`},
+		{"olika förbund", [][]Cell{
+			{Str("Namn"), Str("Personnummer"), Str("Belopp"), Str("Betalkod"), Str("Plats"), Str("Förbund")},
+			{Str("Andersson Anna"), Str("850101-1234"), Str("412"), Str("1"), Str("1"), Str("GS")},
+			{Str("Öberg Åsa"), Str("900303-3456"), Str("295"), Str("1"), Str("1"), Str("IF Metall")},
+		}, `rad 3: Förbund: "IF Metall", men tidigare rader har "GS"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, []Sheet{{Name: "GS", Rows: tt.rows}}); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ReadMembers(bytes.NewReader(buf.Bytes()), int64(buf.Len()), 0)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ReadMembers() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package spec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError is input that could not be read, with where in the
+// input it is. Position fields are zero when not known.
+type ParseError struct {
+	File  string
+	Sheet string // xlsx sheet
+	Page  int    // pdf page, 1 based
+	Line  int    // line in csv or row in xlsx, 1 based
+	Row   string // the cells of the row
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	var parts []string
+	if e.Page > 0 {
+		parts = append(parts, fmt.Sprintf("sida %d", e.Page))
+	}
+	if e.Line > 0 {
+		line := fmt.Sprintf("rad %d", e.Line)
+		if e.Sheet != "" {
+			line = fmt.Sprintf("blad %q %s", e.Sheet, line)
+		}
+		parts = append(parts, line)
+		if e.Row != "" {
+			parts = append(parts, fmt.Sprintf("%q", e.Row))
+		}
+	} else if e.Row != "" {
+		parts = append(parts, fmt.Sprintf("rad %q", e.Row))
+	}
+	if len(parts) == 0 {
+		return e.Err.Error()
+	}
+	return strings.Join(parts, ", ") + ": " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// ValidationError is a field in a location or record that is not valid
+// in a union file
+type ValidationError struct {
+	Location int
+	Record   int    // S2 record in the location, 1 based, 0 for S1 and S3
+	Field    string // Swedish name of the field, like personnummer or belopp
+	Msg      string
+	// Err is set if the value did not fit, see FormatOverflowError
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	where := fmt.Sprintf("plats %d", e.Location)
+	if e.Record > 0 {
+		where += fmt.Sprintf(", rad %d", e.Record)
+	}
+	if e.Msg == "" && e.Err != nil {
+		return where + ": " + e.Err.Error()
+	}
+	return where + ": " + e.Msg
+}
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// EncodingError is a character that can not be written
+// in the encoding of the union file
+type EncodingError struct {
+	Encoding string
+	Rune     rune
+	Value    string // the text with the character
+}
+
+func (e *EncodingError) Error() string {
+	return fmt.Sprintf("tecknet %q i %q kan inte skrivas med teckenkodningen %s", e.Rune, e.Value, e.Encoding)
+}
+
+// FormatOverflowError is a value that does not fit
+// its fixed width field in a record
+type FormatOverflowError struct {
+	Record string // S1, S2 or S3
+	Field  string
+	Value  string
+	Width  int
+}
+
+func (e *FormatOverflowError) Error() string {
+	return fmt.Sprintf("%s %s får inte plats i %s (%d tecken)", e.Field, e.Value, e.Record, e.Width)
+}
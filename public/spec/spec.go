@@ -5,6 +5,7 @@
 package spec
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -20,15 +21,66 @@ const (
 	PayCodeMissingPermission PayCode = 33
 )
 
+// AccountingType is the kind of submission, written as one digit in S1.
+type AccountingType int
+
+const (
+	AccountingNormal     AccountingType = 0 // ordinarie redovisning
+	AccountingCorrection AccountingType = 1 // rättelse, replaces earlier records
+	AccountingSupplement AccountingType = 2 // tillägg, adds to earlier records
+)
+
+func (a AccountingType) String() string {
+	switch a {
+	case AccountingNormal:
+		return "Ordinarie"
+	case AccountingCorrection:
+		return "Rättelse"
+	case AccountingSupplement:
+		return "Tillägg"
+	default:
+		return "Okänd"
+	}
+}
+
 type S1Spec struct {
 	LocNum          int
 	CompanyNum      int
 	CompanyName     string
+	AccountingType  AccountingType
 	Period          int
 	Year            int
 	TransactionDate time.Time
 }
 
+// Provenance tells where a record came from and what was done to it
+type Provenance struct {
+	File  string   `json:"file"`
+	Page  int      `json:"page"` // 1 based
+	Y     float64  `json:"y"`
+	Line  int      `json:"line,omitempty"` // 1 based, for csv files
+	Cells []string `json:"cells"`
+	Steps []string `json:"steps"` // transformations in the order they were applied
+}
+
+// Clone returns a copy that can get steps without changing p
+func (p *Provenance) Clone() *Provenance {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	c.Steps = append([]string{}, p.Steps...)
+	return &c
+}
+
+// AddStep records a transformation, it is a no-op on nil
+func (p *Provenance) AddStep(format string, args ...any) {
+	if p == nil {
+		return
+	}
+	p.Steps = append(p.Steps, fmt.Sprintf(format, args...))
+}
+
 type S2Spec struct {
 	LocNum        int
 	PersonNum     int
@@ -36,6 +88,7 @@ type S2Spec struct {
 	Amount        decimal.Decimal
 	ControlAmount decimal.Decimal
 	PayCode       PayCode
+	Source        *Provenance // nil when not known
 }
 
 type S3Spec struct {
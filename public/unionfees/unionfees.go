@@ -0,0 +1,486 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+// Package unionfees converts payroll reports to the fixed width files
+// submitted to the unions. It is what the cli and the server are built on
+// and can be used by other programs:
+//
+//	in, err := unionfees.ReadFile("fackavgifter.pdf")
+//	...
+//	res, err := unionfees.Convert(ctx, in, unionfees.Options{PayoutDate: date})
+//	...
+//	_, err = res.WriteFiles("utfiler", false)
+package unionfees
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kmpm/unionfees/internal"
+	"github.com/kmpm/unionfees/internal/config"
+	"github.com/kmpm/unionfees/internal/input"
+	"github.com/kmpm/unionfees/internal/output"
+	"github.com/kmpm/unionfees/internal/parser"
+	"github.com/kmpm/unionfees/internal/union"
+	"github.com/kmpm/unionfees/public/spec"
+	"github.com/shopspring/decimal"
+)
+
+// Config is the company configuration. LoadConfig and DefaultConfig are
+// the only supported ways to get one, the fields follow the config file
+// and may change with it.
+type Config = config.Config
+
+// Document is a parsed pdf, to print or inspect. It can not be created
+// outside of this module, a ReadPDF function gets it from the parser in
+// another process.
+type Document = parser.Document
+
+// Limits bound the work done parsing a pdf, see Options.Limits.
+// Zero values mean no limit.
+type Limits struct {
+	MaxSize  int64 // bytes
+	MaxPages int
+	Timeout  time.Duration
+	// MaxStream is the decoded bytes of content and font cmaps on a page
+	MaxStream int64
+	// MaxTexts is the text fragments on a page
+	MaxTexts int
+}
+
+// DefaultLimits are reasonable limits for a server
+var DefaultLimits = Limits(parser.DefaultLimits)
+
+// LoadConfig loads and checks the config at path, or the default
+// locations if path is empty
+func LoadConfig(path string) (*Config, error) {
+	cfg, err := config.Load(path)
+	if err == nil {
+		err = cfg.Check()
+	}
+	if err != nil {
+		return cfg, kind(ErrConfig, fmt.Errorf("felaktig konfiguration: %w", err))
+	}
+	return cfg, nil
+}
+
+// DefaultConfig returns the config used when there is no config file
+func DefaultConfig() *Config {
+	return config.Default()
+}
+
+// Errors about the content of the input or the records, test with
+// errors.As. They are returned by Convert together with a kind below.
+type (
+	ParseError          = spec.ParseError
+	ValidationError     = spec.ValidationError
+	EncodingError       = spec.EncodingError
+	FormatOverflowError = spec.FormatOverflowError
+)
+
+// Kinds of errors returned by Convert, test with errors.Is
+var (
+	ErrConfig  = errors.New("felaktig konfiguration")
+	ErrOptions = errors.New("felaktiga val")
+	ErrInput   = errors.New("felaktig indata")
+	ErrPeriod  = errors.New("felaktig period")
+	// ErrLimit is a pdf that exceeds Options.Limits
+	ErrLimit = parser.ErrLimit
+)
+
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string       { return e.err.Error() }
+func (e *kindError) Unwrap() []error     { return []error{e.kind, e.err} }
+func kind(k error, err error) *kindError { return &kindError{kind: k, err: err} }
+
+// Input is a payroll report or export to convert
+type Input struct {
+	// Name is the file name, it is used to detect the format and
+	// is recorded as the source of every record
+	Name string
+	Data []byte
+	// Format is the name of an input format, empty to detect it
+	Format string
+}
+
+// ReadFile reads the file at path as input
+func ReadFile(path string) (Input, error) {
+	data, err := os.ReadFile(path)
+	return Input{Name: path, Data: data}, err
+}
+
+// Formats returns the names of the input formats with cfg
+func Formats(cfg *Config) ([]string, error) {
+	adapters, err := cfg.Adapters()
+	if err != nil {
+		return nil, kind(ErrConfig, err)
+	}
+	return input.Names(adapters), nil
+}
+
+// Options for Convert
+type Options struct {
+	// Config, nil for the default config
+	Config *Config
+	// CompanyName and OrgNum are taken from the config or
+	// the input if empty
+	CompanyName string
+	OrgNum      string
+	// PayoutDate is required
+	PayoutDate time.Time
+	// Year (YY) and Period (MM), 0 to take them from PayoutDate
+	Year   int
+	Period int
+	// Accounting is normal, correction or supplement
+	Accounting spec.AccountingType
+	// MaxAge is the number of months back a correction may be for,
+	// 0 for the default
+	MaxAge int
+	// Now is used for the period check, zero for the current time
+	Now time.Time
+	// Original is an earlier submission that a correction or supplement
+	// is computed against. It is the file of the union OriginalCode, which
+	// is required with Original, other unions are not compared with it.
+	Original     []spec.S2Spec
+	OriginalCode int
+	// Only keeps these personnummer (10 digits), nil for all
+	Only []int
+	// Unions are the union names to convert, nil for all
+	Unions []string
+	// FileTemplate names the files, empty for the config or the default
+	FileTemplate string
+	// Split makes one file per location
+	Split bool
+	// Test marks the files as a test submission in their names
+	Test bool
+	// Limits bound the parsing of a pdf and the unpacking of an xlsx,
+	// zero for no limits
+	Limits Limits
+	// ReadPDF parses a pdf instead of the built in parser if set,
+	// e.g. in a separate process
+	ReadPDF func(ctx context.Context, data []byte, lim Limits) (*Document, error)
+}
+
+// Result of Convert
+type Result struct {
+	File string
+	// Hash is the hex sha256 of the input, to find files converted before
+	Hash        string
+	Format      string
+	CompanyName string
+	OrgNum      string
+	PayoutDate  time.Time
+	Year        int
+	Period      int
+	Accounting  spec.AccountingType
+	// Document is the parsed pdf, nil for other formats
+	Document *Document
+	Unions   []UnionResult
+	// Diagnostics are things that did not stop the conversion but
+	// should be checked
+	Diagnostics []Diagnostic
+}
+
+// UnionResult is the conversion of one union
+type UnionResult struct {
+	Name string
+	Code int
+	// Configured is false if the union number is a default, not from the config
+	Configured bool
+	// Reviewed is true if the records come from a reviewed member list
+	Reviewed  bool
+	S2        []spec.S2Spec
+	Locations spec.Locations
+	Records   int
+	Sum       decimal.Decimal
+	Files     []File
+}
+
+// File is a union file ready to be written
+type File struct {
+	Name     string
+	Union    string
+	Code     int
+	Location int // 0 when all locations are in the file
+	// Locations are the records in the file
+	Locations spec.Locations
+	Data      []byte
+}
+
+// Diagnostic is a warning about the result
+type Diagnostic struct {
+	Union   string // empty if not about a union
+	Message string
+}
+
+func (d Diagnostic) String() string { return d.Message }
+
+func (r *Result) warn(unionName, format string, args ...any) {
+	r.Diagnostics = append(r.Diagnostics, Diagnostic{Union: unionName, Message: fmt.Sprintf(format, args...)})
+}
+
+// Files returns the files of all unions
+func (r *Result) Files() []File {
+	files := []File{}
+	for _, u := range r.Unions {
+		files = append(files, u.Files...)
+	}
+	return files
+}
+
+// WriteFiles writes every file to dir and returns their paths. Existing
+// files are only overwritten if overwrite is set. The files are written
+// all or none, nothing is left behind when an error is returned.
+func (r *Result) WriteFiles(dir string, overwrite bool) ([]string, error) {
+	files := []output.File{}
+	paths := []string{}
+	for _, f := range r.Files() {
+		path := filepath.Join(dir, f.Name)
+		files = append(files, output.File{Path: path, Data: f.Data})
+		paths = append(paths, path)
+	}
+	if err := output.WriteFiles(files, overwrite); err != nil {
+		return []string{}, err
+	}
+	return paths, nil
+}
+
+// ErrExists is returned by WriteFiles when a file exists
+var ErrExists = output.ErrExists
+
+// ReadOriginal reads an earlier submitted union file, for Options.Original,
+// in the default encoding
+func ReadOriginal(r io.Reader) ([]spec.S2Spec, int, error) {
+	return ReadOriginalEncoding(r, "")
+}
+
+// ReadOriginalEncoding reads an earlier submitted union file written with
+// the named character encoding, as Config.Output.Encoding. Empty is the
+// default encoding.
+func ReadOriginalEncoding(r io.Reader, encoding string) ([]spec.S2Spec, int, error) {
+	enc, err := union.Encoding(encoding)
+	if err != nil {
+		return nil, 0, kind(ErrConfig, err)
+	}
+	locs, code, err := union.ReadTableEncoding(r, enc)
+	if err != nil {
+		return nil, int(code), err
+	}
+	return internal.AllS2(locs), int(code), nil
+}
+
+// Convert parses in, converts every union to S2 records, applies the
+// config, computes corrections, validates and renders the union files.
+// Nothing is written, see Result.WriteFiles. The result is never nil,
+// it has what was done before an error.
+func Convert(ctx context.Context, in Input, opts Options) (*Result, error) {
+	res := &Result{File: in.Name, Unions: []UnionResult{}, Diagnostics: []Diagnostic{}}
+	res.Hash = fmt.Sprintf("%x", sha256.Sum256(in.Data))
+	cfg := opts.Config
+	if cfg == nil {
+		cfg = config.Default()
+	}
+	if err := cfg.Check(); err != nil {
+		return res, kind(ErrConfig, fmt.Errorf("felaktig konfiguration: %w", err))
+	}
+	adapters, err := cfg.Adapters()
+	if err != nil {
+		return res, kind(ErrConfig, err)
+	}
+	enc, err := union.Encoding(cfg.Output.Encoding)
+	if err != nil {
+		return res, kind(ErrConfig, err)
+	}
+	tmpl := cmp.Or(opts.FileTemplate, cfg.Output.Template, output.DefaultTemplate)
+
+	if opts.PayoutDate.IsZero() {
+		return res, kind(ErrOptions, errors.New("utbetalningsdatum saknas"))
+	}
+	res.PayoutDate = opts.PayoutDate
+	res.Year, res.Period = opts.Year, opts.Period
+	if res.Year == 0 {
+		res.Year = opts.PayoutDate.Year() - 2000
+	}
+	if res.Period == 0 {
+		res.Period = int(opts.PayoutDate.Month())
+	}
+	res.Accounting = opts.Accounting
+
+	policy := internal.DefaultPeriodPolicy
+	if opts.MaxAge > 0 {
+		policy.MaxAge = opts.MaxAge
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	periodKind, err := policy.Check(res.Year, res.Period, now)
+	if err != nil {
+		return res, kind(ErrPeriod, fmt.Errorf("felaktig period: %w", err))
+	}
+	if periodKind == internal.PeriodCorrection && opts.Accounting == spec.AccountingNormal {
+		return res, kind(ErrPeriod, fmt.Errorf("period %02d/%02d är stängd och kan bara redovisas som rättelse", res.Period, res.Year))
+	}
+	if opts.Original != nil && opts.Accounting == spec.AccountingNormal {
+		return res, kind(ErrOptions, errors.New("original kan bara anges för rättelse eller tillägg"))
+	}
+	if opts.Original != nil && opts.OriginalCode == 0 {
+		return res, kind(ErrOptions, errors.New("förbundsnummer för original saknas"))
+	}
+	if opts.Accounting != spec.AccountingNormal && opts.Original == nil && opts.Only == nil {
+		res.warn("", "varken original eller urval av personer angivet, alla medlemmar tas med")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+	for _, a := range adapters {
+		switch a := a.(type) {
+		case *input.PDF:
+			a.Limits = parser.Limits(opts.Limits)
+			if opts.ReadPDF != nil {
+				a.Parse = func(ctx context.Context, data []byte, lim parser.Limits) (*Document, error) {
+					return opts.ReadPDF(ctx, data, Limits(lim))
+				}
+			}
+		case *input.XLSX:
+			a.Limits = parser.Limits(opts.Limits)
+		}
+	}
+	data, err := input.Read(ctx, in.Name, in.Data, in.Format, adapters)
+	if err != nil {
+		return res, kind(ErrInput, fmt.Errorf("fel vid läsning av %s: %w", in.Name, err))
+	}
+	res.Format = data.Format
+	res.Document = data.Doc
+
+	res.CompanyName = cmp.Or(opts.CompanyName, cfg.Company.Name, data.CompanyName)
+	if len(res.CompanyName) < 3 {
+		return res, kind(ErrInput, errors.New("namn måste vara längre än 3 tecken"))
+	}
+	res.OrgNum = cmp.Or(opts.OrgNum, cfg.Company.OrgNum, data.OrgNum)
+	companyNum, err := internal.Str2Person(res.OrgNum)
+	if err != nil {
+		return res, kind(ErrInput, fmt.Errorf("felaktigt orgnr: %w", err))
+	}
+
+	names := slices.Sorted(maps.Keys(data.Tables))
+	if len(names) == 0 {
+		res.warn("", "inga förbund hittades i %s", in.Name)
+	}
+	originalUsed := false
+	for _, name := range names {
+		if opts.Unions != nil && !slices.ContainsFunc(opts.Unions, func(u string) bool {
+			return strings.EqualFold(strings.TrimSpace(u), name)
+		}) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		ur := UnionResult{Name: name}
+		code, ok := cfg.UnionCode(name)
+		if !ok {
+			code = union.CodeIFMetall
+			res.warn(name, "förbundsnummer för %s saknas i konfigurationen, använder %d", name, code)
+		}
+		ur.Code, ur.Configured = int(code), ok
+
+		s2s, reviewed, err := data.S2(in.Name, name)
+		if err != nil {
+			return res, kind(ErrInput, fmt.Errorf("%s: %w", name, err))
+		}
+		ur.Reviewed = reviewed
+		if !reviewed {
+			if err := cfg.Apply(s2s); err != nil {
+				return res, kind(ErrConfig, err)
+			}
+		}
+		if opts.Original != nil {
+			if opts.OriginalCode == ur.Code {
+				s2s = internal.Delta(opts.Original, s2s, opts.Accounting)
+				originalUsed = true
+			} else {
+				res.warn(name, "originalet gäller inte %s, alla medlemmar tas med", name)
+			}
+		}
+		if opts.Only != nil {
+			s2s = internal.FilterPersons(s2s, opts.Only)
+		}
+		if len(s2s) == 0 {
+			res.warn(name, "%s har inga medlemmar att redovisa", name)
+		}
+		ur.S2 = s2s
+
+		ur.Locations = internal.BuildLocations(
+			internal.CompanyArgs{
+				CompanyNum:      companyNum,
+				CompanyName:     res.CompanyName,
+				AccountingType:  opts.Accounting,
+				Period:          res.Period,
+				Year:            res.Year,
+				TransactionDate: opts.PayoutDate,
+			},
+			s2s,
+		)
+		ur.Sum = decimal.Zero
+		for _, l := range ur.Locations {
+			ur.Records += l.S3.Records
+			ur.Sum = ur.Sum.Add(l.S3.SumAmout)
+		}
+		if err := union.Validate(ur.Locations); err != nil {
+			res.warn(name, "%s: %v", name, err)
+		}
+		if n, _ := union.Preview(io.Discard, ur.Locations, code); n > 0 {
+			res.warn(name, "%s: %d namn är för långa och avkortas", name, n)
+		}
+
+		files := map[int]spec.Locations{0: ur.Locations}
+		if opts.Split {
+			files = map[int]spec.Locations{}
+			for locnum, l := range ur.Locations {
+				files[locnum] = spec.Locations{locnum: l}
+			}
+		}
+		for _, locnum := range slices.Sorted(maps.Keys(files)) {
+			fileName, err := output.FileName(tmpl, output.NewNameData(name, ur.Code,
+				res.CompanyName, res.OrgNum, res.Year, res.Period, locnum, opts.Test))
+			if err != nil {
+				return res, kind(ErrOptions, err)
+			}
+			var buf bytes.Buffer
+			if err := union.WriteTableEncoding(&buf, files[locnum], code, enc); err != nil {
+				return res, fmt.Errorf("%s: %w", fileName, err)
+			}
+			ur.Files = append(ur.Files, File{
+				Name:      fileName,
+				Union:     name,
+				Code:      ur.Code,
+				Location:  locnum,
+				Locations: files[locnum],
+				Data:      buf.Bytes(),
+			})
+		}
+		res.Unions = append(res.Unions, ur)
+	}
+	if opts.Original != nil && !originalUsed {
+		res.warn("", "originalet är för förbund %d som inte gjordes om", opts.OriginalCode)
+	}
+	return res, nil
+}
@@ -0,0 +1,261 @@
+// SPDX-FileCopyrightText: 2025 Peter Magnusosn <me@kmpm.se>
+//
+// SPDX-License-Identifier: MIT
+
+package unionfees
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kmpm/unionfees/internal/pdftest"
+	"github.com/kmpm/unionfees/public/spec"
+)
+
+var testReport = pdftest.Example()
+
+var (
+	testDate = time.Date(2025, 4, 25, 0, 0, 0, 0, time.UTC)
+	testNow  = time.Date(2025, 4, 28, 0, 0, 0, 0, time.UTC)
+)
+
+func testInput(t *testing.T) Input {
+	t.Helper()
+	data, err := pdftest.Bytes(testReport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Input{Name: "fackavgifter.pdf", Data: data}
+}
+
+func fileNames(r *Result) []string {
+	names := []string{}
+	for _, f := range r.Files() {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func TestConvert(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Locations = map[string]int{"790202-2345": 2}
+	in := testInput(t)
+
+	tests := []struct {
+		name      string
+		opts      Options
+		wantFiles []string
+		wantDiag  string
+	}{
+		{"alla", Options{}, []string{"GS-2504.txt", "IF Metall-2504.txt"}, ""},
+		{"förbund", Options{Unions: []string{"gs"}}, []string{"GS-2504.txt"}, ""},
+		{"per plats", Options{Split: true, Unions: []string{"IF Metall"}},
+			[]string{"IF Metall-2504-0001.txt", "IF Metall-2504-0002.txt"}, ""},
+		{"urval", Options{Accounting: spec.AccountingSupplement, Only: []int{8501011234}},
+			[]string{"GS-2504.txt", "IF Metall-2504.txt"}, "GS har inga medlemmar att redovisa"},
+		{"rättelse", Options{Accounting: spec.AccountingCorrection},
+			[]string{"GS-2504.txt", "IF Metall-2504.txt"}, "varken original eller urval"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Config = cfg
+			tt.opts.PayoutDate = testDate
+			tt.opts.Now = testNow
+			res, err := Convert(context.Background(), in, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Format != "pdf" || res.CompanyName != "Exempel AB" || res.Year != 25 || res.Period != 4 {
+				t.Errorf("Result = %s, %q, %d, %d", res.Format, res.CompanyName, res.Year, res.Period)
+			}
+			if len(res.Hash) != 64 {
+				t.Errorf("Hash = %q, want sha256 in hex", res.Hash)
+			}
+			if got := fileNames(res); !slices.Equal(got, tt.wantFiles) {
+				t.Errorf("Files() = %q, want %q", got, tt.wantFiles)
+			}
+			for _, u := range res.Unions {
+				n := 0
+				for _, f := range u.Files {
+					n += bytes.Count(f.Data, []byte("\n"))
+				}
+				// S1 and S3 lines per location and one S2 line per record
+				if want := 2*len(u.Locations) + u.Records; n != want {
+					t.Errorf("%s har %d rader, want %d", u.Name, n, want)
+				}
+			}
+			if tt.wantDiag != "" && !slices.ContainsFunc(res.Diagnostics, func(d Diagnostic) bool {
+				return strings.Contains(d.Message, tt.wantDiag)
+			}) {
+				t.Errorf("Diagnostics = %v, want %q", res.Diagnostics, tt.wantDiag)
+			}
+		})
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	in := testInput(t)
+	tests := []struct {
+		name    string
+		in      Input
+		opts    Options
+		want    error
+		wantErr string
+	}{
+		{"datum saknas", in, Options{}, ErrOptions, "utbetalningsdatum saknas"},
+		{"stängd period", in, Options{PayoutDate: testDate.AddDate(0, -2, 0)}, ErrPeriod, "är stängd"},
+		{"original", in, Options{PayoutDate: testDate, Original: []spec.S2Spec{}}, ErrOptions, "original kan bara"},
+		{"original utan förbund", in, Options{PayoutDate: testDate, Accounting: spec.AccountingCorrection,
+			Original: []spec.S2Spec{}}, ErrOptions, "förbundsnummer för original saknas"},
+		{"indata", Input{Name: "a.txt", Data: []byte("hej")}, Options{PayoutDate: testDate}, ErrInput, "känner inte igen formatet"},
+		{"konfiguration", in, Options{PayoutDate: testDate, Config: &Config{}}, ErrConfig, "default_location"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Now = testNow
+			res, err := Convert(context.Background(), tt.in, tt.opts)
+			if !errors.Is(err, tt.want) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Convert() error = %v, want %v %q", err, tt.want, tt.wantErr)
+			}
+			if res == nil {
+				t.Error("Convert() result is nil")
+			}
+		})
+	}
+}
+
+func TestConvertCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Convert(ctx, testInput(t), Options{PayoutDate: testDate, Now: testNow})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Convert() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+// TestConvertConcurrent converts with one config on several
+// goroutines at once, run it with -race
+func TestConvertConcurrent(t *testing.T) {
+	cfg := DefaultConfig()
+	in := testInput(t)
+	opts := Options{Config: cfg, PayoutDate: testDate, Now: testNow}
+	want, err := Convert(context.Background(), in, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make(chan *Result, 8)
+	for range cap(results) {
+		go func() {
+			res, err := Convert(context.Background(), in, opts)
+			if err != nil {
+				t.Error(err)
+			}
+			results <- res
+		}()
+	}
+	for range cap(results) {
+		res := <-results
+		for i, f := range res.Files() {
+			if !bytes.Equal(f.Data, want.Files()[i].Data) {
+				t.Errorf("%s differs from a single conversion", f.Name)
+			}
+		}
+	}
+}
+
+func TestConvertOriginal(t *testing.T) {
+	opts := Options{PayoutDate: testDate, Now: testNow, Unions: []string{"IF Metall"}}
+	first, err := Convert(context.Background(), testInput(t), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, code, err := ReadOriginal(bytes.NewReader(first.Files()[0].Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != first.Unions[0].Code || len(original) != 2 {
+		t.Fatalf("ReadOriginal() = %d records, code %d", len(original), code)
+	}
+
+	opts.Accounting = spec.AccountingCorrection
+	opts.Original, opts.OriginalCode = original, code
+	res, err := Convert(context.Background(), testInput(t), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Unions[0].Records; got != 0 {
+		t.Errorf("Records = %d, want 0 when nothing changed", got)
+	}
+}
+
+// TestConvertOriginalOneUnion corrects a report with two unions against
+// the original of one of them
+func TestConvertOriginalOneUnion(t *testing.T) {
+	opts := Options{PayoutDate: testDate, Now: testNow}
+	first, err := Convert(context.Background(), testInput(t), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metall []byte
+	for _, f := range first.Files() {
+		if f.Union == "IF Metall" {
+			metall = f.Data
+		}
+	}
+	original, code, err := ReadOriginal(bytes.NewReader(metall))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts.Accounting = spec.AccountingCorrection
+	opts.Original, opts.OriginalCode = original, code
+	res, err := Convert(context.Background(), testInput(t), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := map[string]int{}
+	for _, u := range res.Unions {
+		records[u.Name] = u.Records
+	}
+	if want := map[string]int{"GS": 1, "IF Metall": 0}; !reflect.DeepEqual(records, want) {
+		t.Errorf("Records = %v, want %v", records, want)
+	}
+	if !slices.ContainsFunc(res.Diagnostics, func(d Diagnostic) bool {
+		return d.Union == "GS" && strings.Contains(d.Message, "originalet gäller inte GS")
+	}) {
+		t.Errorf("Diagnostics = %v, want a warning for GS", res.Diagnostics)
+	}
+}
+
+func TestConvertTypedErrors(t *testing.T) {
+	csv := func(name, pnr, amount string) Input {
+		return Input{Name: "fackavgifter.csv", Data: []byte("Fackförbund;Anst.nr;Namn;Personnummer;Belopp\r\n" +
+			"GS;1;" + name + ";" + pnr + ";" + amount + "\r\n")}
+	}
+	opts := Options{CompanyName: "Exempel AB", OrgNum: "556677-8899", PayoutDate: testDate, Now: testNow}
+
+	_, err := Convert(context.Background(), csv("Anna Andersson", "850101-1234", "fyra"), opts)
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Line != 2 || !errors.Is(err, ErrInput) {
+		t.Errorf("Convert() error = %v, want *ParseError on line 2", err)
+	}
+
+	_, err = Convert(context.Background(), csv("Łukasz Nowak", "850101-1234", "412"), opts)
+	var ee *EncodingError
+	if !errors.As(err, &ee) || ee.Rune != 'Ł' {
+		t.Errorf("Convert() error = %v, want *EncodingError", err)
+	}
+
+	_, err = Convert(context.Background(), csv("Anna Andersson", "850101-1234", "12000"), opts)
+	var oe *FormatOverflowError
+	var ve *ValidationError
+	if !errors.As(err, &oe) || !errors.As(err, &ve) || ve.Field != "belopp" {
+		t.Errorf("Convert() error = %v, want *FormatOverflowError for belopp", err)
+	}
+}